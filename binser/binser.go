@@ -26,7 +26,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-boostio/boostio"
 )
@@ -41,8 +45,72 @@ var (
 	ErrInvalidTypeDescr = errors.New("binser: invalid Boost binary archive type descriptor")
 	ErrTypeNotSupported = errors.New("binser: type not supported")
 	ErrInvalidArrayLen  = errors.New("binser: invalid array type")
+	ErrInvalidBitset    = errors.New("binser: invalid bitset")
+	ErrInvalidCodePoint = errors.New("binser: invalid wide character code point")
+	ErrInvalidNumber    = errors.New("binser: invalid multiprecision number")
+	ErrInvalidDateTime  = errors.New("binser: invalid date/time value")
+	ErrSpecialValue     = errors.New("binser: special date/time value can not be represented")
+	ErrInvalidMatrix    = errors.New("binser: invalid matrix dimensions")
+	ErrInvalidHistogram = errors.New("binser: invalid histogram")
+	ErrInvalidEnum      = errors.New("binser: invalid enum value")
+	ErrLimitExceeded    = errors.New("binser: decoder limit exceeded")
+	ErrInternal         = errors.New("binser: internal error")
+	ErrItemVersion      = errors.New("binser: invalid collection item version")
+	ErrTrailingData     = errors.New("binser: trailing data after the last value")
+	ErrCollectionLen    = errors.New("binser: invalid number of collection elements")
+	ErrMapKeyOrder      = errors.New("binser: map keys can not be ordered")
 )
 
+// A DecodeError describes an error that occurred while decoding a value.
+//
+// Errors.Is and errors.As match the underlying error, Err.
+type DecodeError struct {
+	Offset int64  // offset in the input, in bytes, at which the error was detected.
+	Path   string // Go path of the value being decoded, e.g. "Snapshot.Orders[3].Price".
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("binser: invalid archive at offset %d: %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("binser: could not decode %s at offset %d: %v", e.Path, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// An InvalidUnmarshalError describes an invalid argument passed to Decode.
+// The argument to Decode must be a non-nil pointer.
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	switch {
+	case e.Type == nil:
+		return "binser: Decode(nil)"
+	case e.Type.Kind() != reflect.Ptr:
+		return "binser: Decode(non-pointer " + e.Type.String() + ")"
+	default:
+		return "binser: Decode(nil " + e.Type.String() + ")"
+	}
+}
+
+// An EncodeError describes an error that occurred while encoding a value.
+//
+// Errors.Is and errors.As match the underlying error, Err.
+type EncodeError struct {
+	Offset int64  // offset in the output, in bytes, at which the error was detected.
+	Path   string // Go path of the value being encoded, e.g. "Snapshot.Orders[3].Price".
+	Err    error
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("binser: could not encode %s at offset %d: %v", e.Path, e.Offset, e.Err)
+}
+
+func (e *EncodeError) Unwrap() error { return e.Err }
+
 // Arch describes the size of on-disk pointers.
 type Arch byte
 
@@ -55,8 +123,8 @@ const (
 )
 
 // NewEncoder creates a new encoder.
-func (a Arch) NewEncoder(w io.Writer) *Encoder {
-	enc := newEncoder(w, a)
+func (a Arch) NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	enc := newEncoder(w, a, opts)
 	enc.Header = a.Header()
 	return enc
 }
@@ -74,6 +142,115 @@ func (a Arch) Header() Header {
 	}
 }
 
+// Option configures the parts of the geometry of an archive that are not
+// recorded in its header, and the limits enforced when decoding it.
+type Option func(o *options)
+
+type options struct {
+	wchar int // size of a C++ wchar_t, in bytes.
+	ldbl  int // size of a C++ long double, in bytes.
+	dec10 int // number of decimal digits of a cpp_dec_float.
+
+	maxStr   int   // maximum length of strings and binary objects, in bytes.
+	maxLen   int   // maximum number of elements of collections.
+	maxDepth int   // maximum nesting depth of values.
+	maxBytes int64 // maximum number of bytes read.
+
+	strict bool // whether to reject archives this package would not write.
+}
+
+func newOptions(opts []Option) options {
+	o := options{dec10: 50}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithWCharSize sets the size in bytes of a C++ wchar_t: 2 for UTF-16
+// archives written on Windows, 4 for UTF-32 ones.
+func WithWCharSize(n int) Option {
+	switch n {
+	case 2, 4:
+		// ok.
+	default:
+		panic(fmt.Errorf("binser: invalid wchar_t size %d", n))
+	}
+	return func(o *options) {
+		o.wchar = n
+	}
+}
+
+// WithLongDoubleSize sets the size in bytes of a C++ long double: 16 for
+// x86-64 Linux archives, 12 for x86 Linux ones and 8 when long double is
+// the same as double.
+func WithLongDoubleSize(n int) Option {
+	switch n {
+	case 8, 12, 16:
+		// ok.
+	default:
+		panic(fmt.Errorf("binser: invalid long double size %d", n))
+	}
+	return func(o *options) {
+		o.ldbl = n
+	}
+}
+
+// WithMaxStringLen sets the maximum length, in bytes, of the strings and
+// binary objects a decoder accepts (default: no limit).
+func WithMaxStringLen(n int) Option {
+	return func(o *options) {
+		o.maxStr = n
+	}
+}
+
+// WithMaxCollectionLen sets the maximum number of elements of the
+// collections a decoder accepts (default: no limit).
+func WithMaxCollectionLen(n int) Option {
+	return func(o *options) {
+		o.maxLen = n
+	}
+}
+
+// WithMaxDepth sets the maximum nesting depth of the values a decoder
+// accepts (default: no limit).
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// WithMaxBytes sets the maximum number of bytes a decoder reads from its
+// input, header included (default: no limit).
+func WithMaxBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
+// WithStrict makes a decoder reject the archives it can not decode
+// faithfully: archives with an unknown version or unknown header flags,
+// classes of the standard library or of Boost whose version is not the one
+// the decoder reads, and user classes or collection items whose versions
+// are not consistent across the archive.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithDecFloatDigits sets the number of decimal digits of the
+// boost::multiprecision::cpp_dec_float<Digits10> values held by big.Float
+// values (default: 50, as in cpp_dec_float_50).
+func WithDecFloatDigits(digits10 int) Option {
+	if digits10 <= 0 {
+		panic(fmt.Errorf("binser: invalid cpp_dec_float number of digits %d", digits10))
+	}
+	return func(o *options) {
+		o.dec10 = digits10
+	}
+}
+
 var (
 	zeroHdr   Header
 	bser64Hdr = Header{
@@ -130,7 +307,26 @@ func (hdr *Header) UnmarshalBoost(r *RBuffer) error {
 	return r.err
 }
 
+// sizeofInt returns the size of a C++ int, as recorded in the header.
+func (hdr Header) sizeofInt() int {
+	return int(hdr.Flags & 0xff)
+}
+
+// collectionSize32 reports whether the number of elements of collections is
+// stored as a 32-bits unsigned int, as done by Boost archives up to version 5,
+// instead of a size_t.
+func (hdr Header) collectionSize32() bool {
+	return hdr.Version != 0 && hdr.Version <= 5
+}
+
+// sizeofLong returns the size of a C++ long, as recorded in the header.
+func (hdr Header) sizeofLong() int {
+	return int(hdr.Flags >> 8 & 0xff)
+}
+
 // TypeDescr describes an on-disk binary boost archive type.
+//
+// On disk, the tracking level (Flags) of a class precedes its version.
 type TypeDescr struct {
 	Version uint32
 	Flags   uint8
@@ -140,8 +336,8 @@ func (dt TypeDescr) MarshalBoost(w *WBuffer) error {
 	if w.err != nil {
 		return w.err
 	}
-	w.WriteU32(dt.Version)
 	w.WriteU8(dt.Flags)
+	w.WriteU32(dt.Version)
 	return w.err
 }
 
@@ -149,8 +345,8 @@ func (dt *TypeDescr) UnmarshalBoost(r *RBuffer) error {
 	if r.err != nil {
 		return r.err
 	}
-	dt.Version = r.ReadU32()
 	dt.Flags = r.ReadU8()
+	dt.Version = r.ReadU32()
 	return r.err
 }
 
@@ -188,6 +384,430 @@ func newRegistry() registry {
 	})
 }
 
+// pairOf returns the type used to track the class information of the
+// std::pair<const K, V> elements of a std::map<K, V>.
+func pairOf(rt reflect.Type) reflect.Type {
+	return reflect.StructOf([]reflect.StructField{
+		{Name: "First", Type: rt.Key()},
+		{Name: "Second", Type: rt.Elem()},
+	})
+}
+
+var (
+	bitsetType        = reflect.TypeOf(boostio.Bitset{})
+	dynamicBitsetType = reflect.TypeOf(boostio.DynamicBitset{})
+	wstringType       = reflect.TypeOf(boostio.WString(""))
+	boolsType         = reflect.TypeOf([]bool(nil))
+	longDoubleType    = reflect.TypeOf(boostio.LongDouble{})
+	bigIntType        = reflect.TypeOf(big.Int{})
+	bigFloatType      = reflect.TypeOf(big.Float{})
+	bigRatType        = reflect.TypeOf(big.Rat{})
+
+	uuidType         = reflect.TypeOf(boostio.UUID{})
+	vectorType       = reflect.TypeOf(boostio.Vector(nil))
+	matrixType       = reflect.TypeOf(boostio.Matrix{})
+	histogramType    = reflect.TypeOf(boostio.Histogram{})
+	axesType         = reflect.TypeOf([]boostio.Axis(nil))
+	regularAxisType  = reflect.TypeOf(boostio.RegularAxis{})
+	variableAxisType = reflect.TypeOf(boostio.VariableAxis{})
+	integerAxisType  = reflect.TypeOf(boostio.IntegerAxis{})
+	categoryAxisType = reflect.TypeOf(boostio.CategoryAxis{})
+	strCategoryType  = reflect.TypeOf(boostio.StrCategoryAxis{})
+	weightedSumType  = reflect.TypeOf(boostio.WeightedSum{})
+	weightedSumsType = reflect.TypeOf([]boostio.WeightedSum(nil))
+	stringsType      = reflect.TypeOf([]string(nil))
+	ptreeType        = reflect.TypeOf(boostio.PTree{})
+	ptreeItemType    = reflect.TypeOf(boostio.Pair[string, boostio.PTree]{})
+	dateType         = reflect.TypeOf(boostio.Date{})
+	ptimeType        = reflect.TypeOf(boostio.PTime{})
+	timeDurationType = reflect.TypeOf(boostio.TimeDuration{})
+	enumType         = reflect.TypeOf((*boostio.Enum)(nil)).Elem()
+	byteType         = reflect.TypeOf(byte(0))
+	timeType         = reflect.TypeOf(time.Time{})
+	durationType     = reflect.TypeOf(time.Duration(0))
+
+	// types used to track the class information of the backends of
+	// boost::multiprecision::number<Backend> values.
+	cppIntType      = reflect.TypeOf(struct{ cppInt bool }{})
+	cppDecFloatType = reflect.TypeOf(struct{ cppDecFloat bool }{})
+
+	// types used to track the class information of the
+	// boost::numeric::ublas::unbounded_array<double> storage of vectors and
+	// matrices, and of column-major matrices.
+	unboundedArrayType = reflect.TypeOf(struct{ unboundedArray bool }{})
+	colMajorMatrixType = reflect.TypeOf(struct{ colMajorMatrix bool }{})
+
+	// types used to track the class information of the Boost.Histogram
+	// classes with no Go equivalent.
+	weightedHistogramType = reflect.TypeOf(struct{ weightedHistogram bool }{})
+	axisVariantType       = reflect.TypeOf(struct{ axisVariant bool }{})
+	axisVariantProxyType  = reflect.TypeOf(struct{ axisVariantProxy bool }{})
+	transformIDType       = reflect.TypeOf(struct{ transformID bool }{})
+	intStorageType        = reflect.TypeOf(struct{ intStorage bool }{})
+	intStorageImplType    = reflect.TypeOf(struct{ intStorageImpl bool }{})
+	weightStorageType     = reflect.TypeOf(struct{ weightStorage bool }{})
+	weightStorageImplType = reflect.TypeOf(struct{ weightStorageImpl bool }{})
+)
+
+// classVersions holds the versions of the C++ classes whose version, as
+// set with BOOST_CLASS_VERSION, is not 0.
+var classVersions = map[reflect.Type]uint32{
+	timeDurationType: 1,
+}
+
+// classVersion returns the version of the C++ class of type rt.
+func classVersion(rt reflect.Type) uint32 {
+	if rt.Kind() == reflect.Struct && rt.Name() == "" && rt.NumField() == 1 && rt.Field(0).Name == "MultiIndex" {
+		return multiIndexVersion
+	}
+	return classVersions[rt]
+}
+
+// isUserClass reports whether rt is a user class, whose version is only
+// known from the archive, rather than a class of the standard library or of
+// Boost.
+func isUserClass(rt reflect.Type) bool {
+	return rt.Name() != "" && rt.PkgPath() != "" && rt.PkgPath() != histogramType.PkgPath()
+}
+
+// multiIndexOf returns the type used to track the class information of a
+// boost::multi_index_container whose elements are held in a slice of type rt.
+func multiIndexOf(rt reflect.Type) reflect.Type {
+	return reflect.StructOf([]reflect.StructField{{Name: "MultiIndex", Type: rt}})
+}
+
+// valueVersionOf returns the type used to track the class information of the
+// serialization_version<T> of a multi_index_container of elements of type et.
+func valueVersionOf(et reflect.Type) reflect.Type {
+	return reflect.StructOf([]reflect.StructField{{Name: "ValueVersion", Type: et}})
+}
+
+// nodeOf returns the type used to track the class information of the nodes
+// of a multi_index_container whose elements are held in a slice of type rt.
+func nodeOf(rt reflect.Type) reflect.Type {
+	return reflect.StructOf([]reflect.StructField{{Name: "Node", Type: rt}})
+}
+
+// bucketsOf returns the type used to track the class information of the
+// bucket arrays of the hashed indices of a multi_index_container whose
+// elements are held in a slice of type rt.
+func bucketsOf(rt reflect.Type) reflect.Type {
+	return reflect.StructOf([]reflect.StructField{{Name: "Buckets", Type: rt}})
+}
+
+// tracksNodes reports whether a multi_index_container with the given indices
+// tracks its nodes, ie. whether some of its indices record the order of
+// their elements with pointers to these nodes.
+func tracksNodes(indices []boostio.IndexKind) bool {
+	for _, k := range indices {
+		if k.Positional() {
+			return true
+		}
+	}
+	return false
+}
+
+// multiIndexVersion is the class version of a multi_index_container.
+const multiIndexVersion = 2
+
+// nullPointer is the class ID of a null pointer.
+const nullPointer = -1
+
+// fieldOptions holds the options set on a struct field with a
+// `boost:"[name][,option]..."` tag.
+type fieldOptions struct {
+	multiIndex []boostio.IndexKind // indices of a multi_index_container.
+	carray     bool                // array field held in a C array.
+	array      bool                // elements written with make_array.
+	binary     bool                // bytes written with make_binary_object.
+	len        string              // number of elements of an array or binary field.
+	skip       bool                // field read but discarded when decoding.
+}
+
+// parseTag parses the boost tag of a struct field.
+//
+// The following options are recognized:
+//   - multi_index=kind1|kind2|...: the field is a multi_index_container with
+//     the given index types (e.g. "ordered_unique|sequenced").
+//   - carray: the array field is a C array (e.g. double m[3][4]).
+//   - stdarray: the array field is a std::array or a boost::array (default).
+//   - array: the elements of the slice or array field are written with
+//     make_array, with no count.
+//   - binary: the bytes of the []byte or [N]byte field are written with
+//     make_binary_object, with no count.
+//   - len=N: the number of elements of an array or binary slice field, either
+//     a constant or the name of a preceding integer field of the struct.
+//   - skip: the field is read but discarded when decoding, and written as
+//     usual when encoding.
+func parseTag(f reflect.StructField) (fieldOptions, error) {
+	var o fieldOptions
+	tag, ok := f.Tag.Lookup("boost")
+	if !ok {
+		return o, nil
+	}
+	opts := strings.Split(tag, ",")
+	for _, opt := range opts[1:] {
+		key, val, _ := strings.Cut(opt, "=")
+		switch key {
+		case "multi_index":
+			for _, name := range strings.Split(val, "|") {
+				k, err := boostio.ParseIndexKind(name)
+				if err != nil {
+					return o, fmt.Errorf("binser: invalid tag of field %s: %w", f.Name, err)
+				}
+				o.multiIndex = append(o.multiIndex, k)
+			}
+		case "carray", "stdarray":
+			if f.Type.Kind() != reflect.Array {
+				return o, fmt.Errorf("binser: invalid tag option %q of non-array field %s", opt, f.Name)
+			}
+			o.carray = key == "carray"
+		case "array":
+			o.array = true
+		case "binary":
+			o.binary = true
+		case "len":
+			o.len = val
+		case "skip":
+			o.skip = true
+		default:
+			return o, fmt.Errorf("binser: invalid tag option %q of field %s", opt, f.Name)
+		}
+	}
+	switch {
+	case o.array || o.binary:
+		k := f.Type.Kind()
+		if k != reflect.Slice && k != reflect.Array {
+			return o, fmt.Errorf("binser: invalid array tag of non-array field %s", f.Name)
+		}
+		if o.binary && f.Type.Elem() != byteType {
+			return o, fmt.Errorf("binser: invalid binary tag of non-byte field %s", f.Name)
+		}
+		if k == reflect.Slice && o.len == "" {
+			return o, fmt.Errorf("binser: missing len option of slice field %s", f.Name)
+		}
+	case o.len != "":
+		return o, fmt.Errorf("binser: invalid len option of field %s", f.Name)
+	}
+	return o, nil
+}
+
+// lenOf returns the number of elements of the field f, at index i of the
+// struct sv, tagged with the array or binary options o.
+func lenOf(sv reflect.Value, i int, f reflect.Value, o fieldOptions) (int, error) {
+	if o.len == "" {
+		return f.Len(), nil
+	}
+	n, err := strconv.Atoi(o.len)
+	if err != nil {
+		sf, ok := sv.Type().FieldByName(o.len)
+		if !ok || len(sf.Index) != 1 || sf.Index[0] >= i {
+			return 0, fmt.Errorf("binser: invalid len field %q: not a preceding field", o.len)
+		}
+		v := sv.Field(sf.Index[0])
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = int(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = int(v.Uint())
+		default:
+			return 0, fmt.Errorf("binser: invalid len field %q", o.len)
+		}
+	}
+	if n < 0 || (f.Kind() == reflect.Array && n != f.Len()) {
+		return 0, ErrInvalidArrayLen
+	}
+	return n, nil
+}
+
+// isCArray reports whether values of type rt are the elements of a
+// multi-dimensional C array, ie. arrays themselves.
+func isCArray(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Array && rt != uuidType
+}
+
+// bitsetOf returns the type used to track the class information of a
+// std::bitset<n>.
+func bitsetOf(n int) reflect.Type {
+	return reflect.ArrayOf(n, bitsetType)
+}
+
+// makeDuration returns the duration of the given hours, minutes, seconds
+// and microseconds. A negative duration has all its non-zero parts
+// negative.
+func makeDuration(h, m, s, us int64) time.Duration {
+	neg := h < 0 || m < 0 || s < 0 || us < 0
+	d := time.Duration(abs(h)*3600+abs(m)*60+abs(s))*time.Second +
+		time.Duration(abs(us))*time.Microsecond
+	if neg {
+		d = -d
+	}
+	return d
+}
+
+// splitDuration returns the hours, minutes, seconds and microseconds of d.
+func splitDuration(d time.Duration) (h, m, s, us int64) {
+	us = int64(d / time.Microsecond)
+	h, us = us/3600e6, us%3600e6
+	m, us = us/60e6, us%60e6
+	s, us = us/1e6, us%1e6
+	return h, m, s, us
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// allocChunk is the maximum number of bytes allocated ahead of reading the
+// data whose length is taken from the stream. Larger values are grown as
+// their data is read, so a corrupted length fails with io.ErrUnexpectedEOF
+// instead of allocating huge amounts of memory.
+const allocChunk = 1 << 16
+
+// pollPeriod is the number of elements of a collection processed between
+// two checks of the context of a decoding or encoding.
+const pollPeriod = 1024
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// growSlice grows the capacity of the slice rv towards n elements, without
+// allocating more than allocChunk bytes ahead of the data read.
+// The length of rv is left unchanged.
+func growSlice(rv reflect.Value, n int) {
+	len := rv.Len()
+	if n <= rv.Cap() {
+		return
+	}
+	sz := int(rv.Type().Elem().Size())
+	if sz == 0 {
+		sz = 1
+	}
+	grown := reflect.MakeSlice(rv.Type(), len, minInt(n, len+allocChunk/sz+1))
+	reflect.Copy(grown, rv)
+	rv.Set(grown)
+}
+
+// budgetReader reads from r, and fails once n bytes have been read.
+type budgetReader struct {
+	r io.Reader
+	n int64
+}
+
+func (br *budgetReader) Read(p []byte) (int, error) {
+	if br.n <= 0 {
+		return 0, fmt.Errorf("%w: byte budget exhausted", ErrLimitExceeded)
+	}
+	if int64(len(p)) > br.n {
+		p = p[:br.n]
+	}
+	n, err := br.r.Read(p)
+	br.n -= int64(n)
+	return n, err
+}
+
+// path is the Go path of the value being decoded or encoded.
+//
+// Its first element is the name of the type of the top-level value.
+// Map entries are identified by their index in the archive, followed by
+// first or second for their key or value.
+type path []pathElem
+
+// pathElem is an element of a path: a struct field name, or a collection
+// index if name is empty.
+type pathElem struct {
+	name string
+	idx  int
+}
+
+func (p path) String() string {
+	var o strings.Builder
+	for i, e := range p {
+		switch {
+		case i == 0:
+			o.WriteString(e.name)
+		case e.name != "":
+			o.WriteString(".")
+			o.WriteString(e.name)
+		default:
+			fmt.Fprintf(&o, "[%d]", e.idx)
+		}
+	}
+	return o.String()
+}
+
+// push appends the struct field or map entry member name to the path.
+func (p *path) push(name string) { *p = append(*p, pathElem{name: name}) }
+
+// index sets the collection index of the last element of the path.
+func (p *path) index(i int) { (*p)[len(*p)-1].idx = i }
+
+// pop removes the last element of the path.
+func (p *path) pop() { *p = (*p)[:len(*p)-1] }
+
+// typeName returns the name of the type rt, stripped of its pointers.
+func typeName(rt reflect.Type) string {
+	if rt == nil {
+		return "<nil>"
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Name() != "" {
+		return rt.Name()
+	}
+	return rt.String()
+}
+
+// isEnum reports whether values of type rt hold the values of a C++ enum.
+func isEnum(rt reflect.Type) bool {
+	switch rt.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rt.Implements(enumType)
+	}
+	return false
+}
+
+// enumValue returns the value of the enum held by rv.
+func enumValue(rv reflect.Value) int64 {
+	switch rv.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	default:
+		return int64(rv.Uint())
+	}
+}
+
+// setEnum stores the value v in the enum rv. setEnum returns
+// ErrInvalidEnum if v is not one of the allowed values of the enum, or if it
+// overflows rv.
+func setEnum(rv reflect.Value, v int64) error {
+	switch rv.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.OverflowInt(v) {
+			return fmt.Errorf("%w %d for %v", ErrInvalidEnum, v, rv.Type())
+		}
+		rv.SetInt(v)
+	default:
+		if v < 0 || rv.OverflowUint(uint64(v)) {
+			return fmt.Errorf("%w %d for %v", ErrInvalidEnum, v, rv.Type())
+		}
+		rv.SetUint(uint64(v))
+	}
+	if !boostio.ValidEnum(rv.Interface().(boostio.Enum), v) {
+		return fmt.Errorf("%w %v (%d) for %v", ErrInvalidEnum, rv.Interface(), v, rv.Type())
+	}
+	return nil
+}
+
 func isCxxBoostBuiltin(k reflect.Kind) bool {
 	switch k {
 	case reflect.Bool,
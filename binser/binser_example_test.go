@@ -239,3 +239,38 @@ func ExampleEncoder_32b() {
 	// string: hello
 	// int32:  0x44444444
 }
+
+func ExampleDecoder_OpenCollection() {
+	type record struct {
+		ID    int32
+		Value float64
+	}
+
+	buf := new(bytes.Buffer)
+	err := binser.NewEncoder(buf).Encode([]record{{1, 1.5}, {2, 2.5}, {3, 3.5}})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dec := binser.NewDecoder(buf)
+	c, err := dec.OpenCollection(new([]record))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("records: %d\n", c.Len())
+
+	var rec record
+	for c.More() {
+		err := c.Decode(&rec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("record: %+v\n", rec)
+	}
+
+	// Output:
+	// records: 3
+	// record: {ID:1 Value:1.5}
+	// record: {ID:2 Value:2.5}
+	// record: {ID:3 Value:3.5}
+}
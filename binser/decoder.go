@@ -5,38 +5,297 @@
 package binser
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
+	"time"
+
+	"github.com/go-boostio/boostio"
 )
 
 // A Decoder reads and decodes values from a Boost binary serialization stream.
 type Decoder struct {
 	r      *RBuffer
 	Header Header
+
+	path path // path of the value being decoded.
 }
 
 // NewDecoder returns a new decoder that reads from r.
 //
 // The decoder checks the stream has a correct Boost binary header.
-func NewDecoder(r io.Reader) *Decoder {
-	rr := NewRBuffer(r)
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	rr := NewRBuffer(r, opts...)
 	return &Decoder{r: rr, Header: rr.ReadHeader()}
 }
 
+// InputOffset returns the offset, in bytes, of the current position in the
+// input of the decoder, header included.
+func (dec *Decoder) InputOffset() int64 {
+	return dec.r.off
+}
+
 // Decode reads the next value from its input and stores it in the
 // value pointed to by ptr.
+//
+// Errors that occur while decoding the value are returned as a *DecodeError,
+// except for io.EOF when the input is exhausted before the value starts.
+// An *InvalidUnmarshalError is returned if ptr is not a non-nil pointer.
+// Panics of Unmarshaler implementations are propagated to the caller, and
+// leave the decoder in a failed state.
 func (dec *Decoder) Decode(ptr interface{}) error {
 	if dec.r.err != nil {
 		return dec.r.err
 	}
+	if rv := reflect.ValueOf(ptr); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(ptr)}
+	}
+	return dec.run(ptr, func() error { return dec.decode(ptr) })
+}
+
+// DecodeContext is like Decode, but stops decoding with the error of ctx,
+// annotated as a *DecodeError, once ctx is done.
+// The decoder can not be used any further after such an error.
+//
+// The context is checked periodically while decoding collections.
+func (dec *Decoder) DecodeContext(ctx context.Context, ptr interface{}) error {
+	defer func(ctx context.Context) {
+		dec.r.ctx = ctx
+	}(dec.r.ctx)
+	dec.r.ctx = ctx
+	return dec.Decode(ptr)
+}
+
+// Finish checks the input of the decoder holds no data past the last
+// decoded value, and reports the first error of the decoder otherwise.
+// Trailing data is reported as an error matching ErrTrailingData.
+//
+// The decoder can not be used any further after Finish.
+func (dec *Decoder) Finish() error {
+	switch dec.r.err {
+	case nil:
+		// ok.
+	case io.EOF:
+		return nil
+	default:
+		return dec.r.err
+	}
+
+	r := dec.r.r
+	if br, ok := r.(*budgetReader); ok {
+		// data past the byte budget is still trailing data.
+		r = br.r
+	}
+	switch _, err := io.ReadFull(r, dec.r.buf[:1]); err {
+	case io.EOF:
+		dec.r.err = io.EOF
+		return nil
+	case nil:
+		dec.r.err = dec.fail(ErrTrailingData)
+	default:
+		dec.r.err = dec.fail(err)
+	}
+	return dec.r.err
+}
+
+// run runs f, which decodes the value pointed to by ptr, one level deeper
+// than the current value.
+// Panics of f are recovered as errors matching ErrInternal, except the
+// ones of user Unmarshalers which are propagated to the caller of Decode.
+// The errors of f are annotated with the path of the value being decoded
+// and the input offset at which they occurred.
+func (dec *Decoder) run(ptr interface{}, f func() error) (err error) {
+	if dec.r.err != nil {
+		return dec.r.err
+	}
+
+	n := len(dec.path)
+	depth := dec.r.depth
+	if depth == 0 {
+		n = 0
+		dec.path = append(dec.path[:0], pathElem{name: typeName(reflect.TypeOf(ptr))})
+		dec.r.poll(0)
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			// the value is only partly decoded: the input can not be
+			// decoded any further.
+			dec.r.depth = depth
+			up, ok := e.(userPanic)
+			switch {
+			case !ok:
+				dec.r.err = dec.fail(fmt.Errorf("%w: %v", ErrInternal, e))
+			case dec.r.err == nil:
+				dec.r.err = dec.fail(fmt.Errorf("binser: %T.UnmarshalBoost panicked: %v", up.ptr, up.v))
+			}
+			dec.path = dec.path[:n]
+			if ok {
+				if depth == 0 {
+					panic(up.v)
+				}
+				panic(up)
+			}
+			err = dec.r.err
+			return
+		}
+		dec.path = dec.path[:n]
+	}()
+
+	off := dec.r.off
+	dec.r.enter()
+	if dec.r.err == nil {
+		err = f()
+	}
+	dec.r.leave()
+	if dec.r.err == nil && err != nil && (dec.r.depth > 0 || dec.r.off != off) {
+		// the value, or its enclosing value, is only partly decoded: the
+		// input can not be decoded any further.
+		dec.r.err = err
+	}
+	if dec.r.err != nil {
+		dec.r.err = dec.fail(dec.r.err)
+		return dec.r.err
+	}
+	return dec.fail(err)
+}
+
+// userPanic is a panic raised by the Unmarshaler ptr, which run does not
+// recover.
+type userPanic struct {
+	ptr Unmarshaler
+	v   interface{}
+}
 
+// unmarshal calls the UnmarshalBoost method of v.
+// Its panics are marked as user panics, so they reach the caller of Decode.
+func (dec *Decoder) unmarshal(v Unmarshaler) error {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(userPanic); !ok {
+				e = userPanic{ptr: v, v: e}
+			}
+			panic(e)
+		}
+	}()
+	return v.UnmarshalBoost(dec.r)
+}
+
+// fail returns err annotated with the path of the value being decoded and
+// the current input offset.
+func (dec *Decoder) fail(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	var e *DecodeError
+	if errors.As(err, &e) {
+		return err
+	}
+	return &DecodeError{Offset: dec.r.off, Path: dec.path.String(), Err: err}
+}
+
+func (dec *Decoder) decode(ptr interface{}) error {
 	if v, ok := ptr.(Unmarshaler); ok {
-		return v.UnmarshalBoost(dec.r)
+		return dec.unmarshal(v)
+	}
+
+	switch v := ptr.(type) {
+	case *boostio.Bitset:
+		*v = dec.r.ReadBitset(v.Len())
+		return dec.r.err
+	case *boostio.DynamicBitset:
+		*v = dec.r.ReadDynamicBitset()
+		return dec.r.err
+	case *[]bool:
+		*v = dec.r.ReadVectorBool()
+		return dec.r.err
+	case *boostio.WString:
+		*v = boostio.WString(dec.r.ReadWString())
+		return dec.r.err
+	case *boostio.LongDouble:
+		*v = dec.r.ReadLongDouble()
+		return dec.r.err
+	case *big.Int:
+		v.Set(dec.r.ReadBigInt())
+		return dec.r.err
+	case **big.Int:
+		*v = dec.r.ReadBigInt()
+		return dec.r.err
+	case *big.Float:
+		v.Set(dec.r.ReadBigFloat())
+		return dec.r.err
+	case **big.Float:
+		*v = dec.r.ReadBigFloat()
+		return dec.r.err
+	case *big.Rat:
+		v.Set(dec.r.ReadBigRat())
+		return dec.r.err
+	case **big.Rat:
+		*v = dec.r.ReadBigRat()
+		return dec.r.err
+	case *boostio.UUID:
+		*v = dec.r.ReadUUID()
+		return dec.r.err
+	case *boostio.Vector:
+		*v = dec.r.ReadVector()
+		return dec.r.err
+	case *boostio.Matrix:
+		*v = dec.r.ReadMatrix(v.ColMajor)
+		return dec.r.err
+	case *boostio.Histogram:
+		*v = dec.r.ReadHistogram(v.Variant, v.Weighted)
+		return dec.r.err
+	case *boostio.PTree:
+		*v = dec.r.ReadPTree()
+		return dec.r.err
+	case *boostio.Date:
+		*v = dec.r.ReadDate()
+		return dec.r.err
+	case *boostio.PTime:
+		*v = dec.r.ReadPTime()
+		return dec.r.err
+	case *boostio.TimeDuration:
+		*v = dec.r.ReadTimeDuration()
+		return dec.r.err
+	case *time.Time:
+		t := dec.r.ReadPTime()
+		if dec.r.err != nil {
+			return dec.r.err
+		}
+		switch t.Special {
+		case boostio.NotSpecial, boostio.NotADateTime:
+			*v = t.Time
+		default:
+			return ErrSpecialValue
+		}
+		return nil
+	case *time.Duration:
+		d := dec.r.ReadTimeDuration()
+		if dec.r.err != nil {
+			return dec.r.err
+		}
+		if d.Special != boostio.NotSpecial {
+			return ErrSpecialValue
+		}
+		*v = d.Duration
+		return nil
 	}
 
 	rv := reflect.Indirect(reflect.ValueOf(ptr))
 	rt := rv.Type()
 
+	if isEnum(rt) {
+		v := dec.r.ReadEnum()
+		if dec.r.err != nil {
+			return dec.r.err
+		}
+		dec.r.err = setEnum(rv, v)
+		return dec.r.err
+	}
+
 	switch rv.Kind() {
 	case reflect.Bool:
 		rv.SetBool(dec.r.ReadBool())
@@ -68,52 +327,632 @@ func (dec *Decoder) Decode(ptr interface{}) error {
 		rv.SetString(dec.r.ReadString())
 	case reflect.Struct:
 		/*typ*/ _ = dec.r.ReadTypeDescr(rt)
+		if dec.r.err != nil {
+			return dec.r.err
+		}
 		rt := rv.Type()
 		for i := 0; i < rt.NumField(); i++ {
-			dec.Decode(rv.Field(i).Addr().Interface())
+			dec.path.push(rt.Field(i).Name)
+			opts, err := parseTag(rt.Field(i))
+			if err != nil {
+				return err
+			}
+			field := rv.Field(i)
+			if opts.skip {
+				// the field is read into a scratch value, which is discarded.
+				field = scratchOf(field)
+			}
+			switch {
+			case opts.multiIndex != nil:
+				err = dec.decodeMultiIndex(field, opts.multiIndex)
+				if err != nil {
+					return err
+				}
+			case opts.carray:
+				err = dec.decodeCArray(field)
+				if err != nil {
+					return err
+				}
+			case opts.array, opts.binary:
+				n, err := lenOf(rv, i, field, opts)
+				if err != nil {
+					return err
+				}
+				switch {
+				case opts.binary:
+					err = dec.decodeBinaryObject(field, n)
+				default:
+					err = dec.decodeArray(field, n)
+				}
+				if err != nil {
+					return err
+				}
+			case opts.skip && !isSpecial(field.Type()):
+				if err := dec.skipValue(field); err != nil {
+					return err
+				}
+			default:
+				if err := dec.Decode(field.Addr().Interface()); err != nil {
+					return err
+				}
+			}
+			dec.path.pop()
 		}
 	case reflect.Slice:
 		rt := rv.Type()
 		/*typ*/ _ = dec.r.ReadTypeDescr(rt)
 		n := dec.r.readLen()
-		if et := rt.Elem(); !isCxxBoostBuiltin(et.Kind()) {
-			_ = dec.r.ReadU32() // FIXME(sbinet): what is this ?
+		if et := rt.Elem(); !isCxxBoostBuiltin(et.Kind()) || isEnum(et) {
+			dec.r.readItemVersion(et)
 		}
 
-		if len, n := rv.Len(), int(n); len < n {
-			rv.Set(reflect.AppendSlice(rv, reflect.MakeSlice(rv.Type(), n-len, n)))
-		}
-		for i := 0; i < int(n); i++ {
+		growSlice(rv, n)
+		dec.path.push("")
+		for i := 0; i < n && dec.r.err == nil; i++ {
+			dec.r.poll(i)
+			if i == rv.Len() {
+				rv.Set(reflect.Append(rv, reflect.Zero(rt.Elem())))
+			}
 			e := rv.Index(i)
-			dec.Decode(e.Addr().Interface()) // FIXME(sbinet): do not go through Decode each time
+			dec.path.index(i)
+			if err := dec.Decode(e.Addr().Interface()); err != nil { // FIXME(sbinet): do not go through Decode each time
+				return err
+			}
 		}
+		dec.path.pop()
 	case reflect.Array:
 		/*typ*/ _ = dec.r.ReadTypeDescr(rt)
 		n := dec.r.readLen()
 		if n != rv.Type().Len() {
 			return ErrInvalidArrayLen
 		}
+		dec.path.push("")
 		for i := 0; i < n; i++ {
+			dec.r.poll(i)
 			e := rv.Index(i)
-			dec.Decode(e.Addr().Interface()) // FIXME(sbinet): do not go through Decode each time
+			dec.path.index(i)
+			if err := dec.Decode(e.Addr().Interface()); err != nil { // FIXME(sbinet): do not go through Decode each time
+				return err
+			}
 		}
+		dec.path.pop()
 	case reflect.Map:
 		/*typ*/ _ = dec.r.ReadTypeDescr(rt)
 		n := dec.r.readLen()
-		_ = dec.r.ReadU64() // FIXME(sbinet): what is this ?
-		_ = dec.r.ReadU8()  // FIXME(sbinet): ditto ?
 		kt := rv.Type().Key()
 		vt := rv.Type().Elem()
+		pt := pairOf(rt)
+		dec.r.readItemVersion(pt)
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rt))
+		}
+		dec.path.push("")
 		for i := 0; i < n; i++ {
+			dec.r.poll(i)
+			/*typ*/ _ = dec.r.ReadTypeDescr(pt)
+			dec.path.index(i)
 			k := reflect.New(kt)
-			dec.Decode(k.Interface()) // FIXME(sbinet): do not go through Decode each time
+			dec.path.push("first")
+			if err := dec.Decode(k.Interface()); err != nil { // FIXME(sbinet): do not go through Decode each time
+				return err
+			}
+			dec.path.pop()
 			v := reflect.New(vt)
-			dec.Decode(v.Interface()) // FIXME(sbinet): do not go through Decode each time
+			dec.path.push("second")
+			if err := dec.Decode(v.Interface()); err != nil { // FIXME(sbinet): do not go through Decode each time
+				return err
+			}
+			dec.path.pop()
 			rv.SetMapIndex(k.Elem(), v.Elem())
 		}
+		dec.path.pop()
+
+	default:
+		return ErrTypeNotSupported
+	}
+	return dec.r.err
+}
+
+// Skip reads the next value of type typ from its input and discards it.
+//
+// Values are skipped without being stored: only the class information
+// they hold is recorded, so that the values following them can be decoded.
+// Values of the types of the boostio package and values implementing
+// Unmarshaler are decoded into a zero value of typ, which is discarded.
+// Bitsets, whose size is not recorded in the archive, are rejected with an
+// error matching ErrTypeNotSupported: they can only be skipped as part of
+// a field tagged with the skip option, which holds their size.
+//
+// Errors that occur while skipping the value are returned as a *DecodeError.
+// An *InvalidUnmarshalError is returned if typ is nil.
+func (dec *Decoder) Skip(typ reflect.Type) error {
+	if dec.r.err != nil {
+		return dec.r.err
+	}
+	if typ == nil {
+		return &InvalidUnmarshalError{}
+	}
+	return dec.skipValue(reflect.Zero(typ))
+}
+
+func (dec *Decoder) skipValue(rv reflect.Value) error {
+	ptr := reflect.Zero(reflect.PtrTo(rv.Type())).Interface()
+	return dec.run(ptr, func() error { return dec.skip(rv) })
+}
+
+// skip reads and discards a value of the type of rv, which is read as if
+// it were decoded into rv: rv holds the settings of the value, e.g. the
+// number of bits of a bitset, and is left untouched.
+// It mirrors decode, which must be kept in sync.
+func (dec *Decoder) skip(rv reflect.Value) error {
+	rt := rv.Type()
+	if isSpecial(rt) {
+		if rt == bitsetType && rv.CanInterface() {
+			if b := rv.Interface().(boostio.Bitset); b.Len() == 0 {
+				// the size of a bitset is not recorded in the archive.
+				return fmt.Errorf("%w: bitset of unknown size", ErrTypeNotSupported)
+			}
+		}
+		return dec.decode(scratchOf(rv).Addr().Interface())
+	}
+
+	if isEnum(rt) {
+		_ = dec.r.ReadEnum()
+		return dec.r.err
+	}
+
+	if n := sizeOf(rt); n > 0 {
+		dec.r.skipBytes(int64(n))
+		return dec.r.err
+	}
+
+	switch rt.Kind() {
+	case reflect.String:
+		n := dec.r.readStrLen()
+		dec.r.skipBytes(int64(n))
+	case reflect.Struct:
+		for i := 0; i < rt.NumField(); i++ {
+			opts, err := parseTag(rt.Field(i))
+			if err != nil {
+				return err
+			}
+			if opts.multiIndex != nil || opts.carray || opts.array || opts.binary {
+				// the layout of the struct depends on its content.
+				return dec.decode(scratchOf(rv).Addr().Interface())
+			}
+		}
+		/*typ*/ _ = dec.r.ReadTypeDescr(rt)
+		if dec.r.err != nil {
+			return dec.r.err
+		}
+		for i := 0; i < rt.NumField(); i++ {
+			dec.path.push(rt.Field(i).Name)
+			if err := dec.skipValue(rv.Field(i)); err != nil {
+				return err
+			}
+			dec.path.pop()
+		}
+	case reflect.Slice, reflect.Array:
+		/*typ*/ _ = dec.r.ReadTypeDescr(rt)
+		n := dec.r.readLen()
+		et := rt.Elem()
+		switch rt.Kind() {
+		case reflect.Slice:
+			if !isCxxBoostBuiltin(et.Kind()) || isEnum(et) {
+				dec.r.readItemVersion(et)
+			}
+		default:
+			if dec.r.err == nil && n != rt.Len() {
+				return ErrInvalidArrayLen
+			}
+		}
+		if sz := sizeOf(et); sz > 0 && !isEnum(et) {
+			if int64(n) > math.MaxInt64/int64(sz) {
+				return fmt.Errorf("%w: collection length %d overflows int64", ErrLimitExceeded, n)
+			}
+			dec.r.skipBytes(int64(n) * int64(sz))
+			return dec.r.err
+		}
+		dec.path.push("")
+		for i := 0; i < n && dec.r.err == nil; i++ {
+			dec.r.poll(i)
+			dec.path.index(i)
+			if err := dec.skipValue(elemOf(rv, i)); err != nil {
+				return err
+			}
+		}
+		dec.path.pop()
+	case reflect.Map:
+		/*typ*/ _ = dec.r.ReadTypeDescr(rt)
+		n := dec.r.readLen()
+		pt := pairOf(rt)
+		dec.r.readItemVersion(pt)
+		dec.path.push("")
+		for i := 0; i < n && dec.r.err == nil; i++ {
+			dec.r.poll(i)
+			/*typ*/ _ = dec.r.ReadTypeDescr(pt)
+			dec.path.index(i)
+			dec.path.push("first")
+			if err := dec.skipValue(reflect.Zero(rt.Key())); err != nil {
+				return err
+			}
+			dec.path.pop()
+			dec.path.push("second")
+			if err := dec.skipValue(reflect.Zero(rt.Elem())); err != nil {
+				return err
+			}
+			dec.path.pop()
+		}
+		dec.path.pop()
+	default:
+		return ErrTypeNotSupported
+	}
+	return dec.r.err
+}
+
+// elemOf returns the i-th element of the slice or array rv, into which the
+// i-th element of a collection is decoded, or a zero element past the
+// length of rv.
+func elemOf(rv reflect.Value, i int) reflect.Value {
+	if i < rv.Len() {
+		return rv.Index(i)
+	}
+	return reflect.Zero(rv.Type().Elem())
+}
+
+// scratchOf returns a new value of the type of f, to read a value of f
+// into and discard it. It holds the settings of f and of the values it
+// holds the reading depends on, e.g. the number of bits of a bitset, but
+// does not share any storage with f.
+// It must be kept in sync with decode.
+func scratchOf(f reflect.Value) reflect.Value {
+	v := reflect.New(f.Type()).Elem()
+	if !f.CanInterface() {
+		return v
+	}
+	switch fv := f.Interface().(type) {
+	case boostio.Bitset:
+		v.Set(reflect.ValueOf(*boostio.NewBitset(fv.Len())))
+		return v
+	case boostio.Matrix:
+		v.Set(reflect.ValueOf(boostio.Matrix{ColMajor: fv.ColMajor}))
+		return v
+	case boostio.Histogram:
+		v.Set(reflect.ValueOf(boostio.Histogram{Variant: fv.Variant, Weighted: fv.Weighted}))
+		return v
+	}
+	if isSpecial(f.Type()) {
+		return v
+	}
+	switch f.Kind() {
+	case reflect.Struct:
+		for i := 0; i < f.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				v.Field(i).Set(scratchOf(f.Field(i)))
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		if f.Len() == 0 || isCxxBoostBuiltin(f.Type().Elem().Kind()) {
+			break
+		}
+		if f.Kind() == reflect.Slice {
+			// the elements of a slice are decoded in place.
+			v.Set(reflect.MakeSlice(f.Type(), f.Len(), f.Len()))
+		}
+		for i := 0; i < f.Len(); i++ {
+			v.Index(i).Set(scratchOf(f.Index(i)))
+		}
+	}
+	return v
+}
+
+// isSpecial reports whether values of type rt are decoded by a dedicated
+// method of RBuffer, rather than from their kind.
+// It must be kept in sync with decode.
+func isSpecial(rt reflect.Type) bool {
+	ptr := reflect.Zero(reflect.PtrTo(rt)).Interface()
+	if _, ok := ptr.(Unmarshaler); ok {
+		return true
+	}
+	switch ptr.(type) {
+	case *boostio.Bitset, *boostio.DynamicBitset, *[]bool, *boostio.WString,
+		*boostio.LongDouble,
+		*big.Int, **big.Int, *big.Float, **big.Float, *big.Rat, **big.Rat,
+		*boostio.UUID, *boostio.Vector, *boostio.Matrix, *boostio.Histogram,
+		*boostio.PTree, *boostio.Date, *boostio.PTime, *boostio.TimeDuration,
+		*time.Time, *time.Duration:
+		return true
+	}
+	return false
+}
+
+// sizeOf returns the number of bytes of the values of type rt, if they are
+// written with a fixed size, and 0 otherwise.
+func sizeOf(rt reflect.Type) int {
+	switch rt.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4
+	case reflect.Int64, reflect.Uint64, reflect.Float64, reflect.Complex64:
+		return 8
+	case reflect.Complex128:
+		return 16
+	}
+	return 0
+}
+
+// A Collection decodes the elements of a collection one at a time, so that
+// collections larger than memory can be processed.
+//
+// The decoder of a collection must not be used for other values until all
+// the elements of the collection have been decoded.
+type Collection struct {
+	dec  *Decoder
+	rt   reflect.Type // type of the slice holding the collection.
+	typ  TypeDescr
+	n    int    // number of elements.
+	i    int    // index of the next element.
+	vers uint32 // item_version.
+}
+
+// OpenCollection reads the class information, the number of elements and
+// the item version of the next value, a collection such as a std::vector
+// described by the type of the slice pointed to by ptr, and returns a
+// Collection to decode its elements one at a time.
+//
+// The slice pointed to by ptr is left untouched.
+func (dec *Decoder) OpenCollection(ptr interface{}) (*Collection, error) {
+	if dec.r.err != nil {
+		return nil, dec.r.err
+	}
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, &InvalidUnmarshalError{Type: reflect.TypeOf(ptr)}
+	}
+	c := &Collection{dec: dec, rt: rv.Type().Elem()}
+	err := dec.run(ptr, func() error {
+		if c.rt.Kind() != reflect.Slice {
+			return ErrTypeNotSupported
+		}
+		c.typ = dec.r.ReadTypeDescr(c.rt)
+		c.n = dec.r.readLen()
+		if et := c.rt.Elem(); !isCxxBoostBuiltin(et.Kind()) || isEnum(et) {
+			c.vers = dec.r.readItemVersion(et)
+		}
+		return dec.r.err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// TypeDescr returns the class information of the collection.
+func (c *Collection) TypeDescr() TypeDescr { return c.typ }
+
+// Len returns the number of elements of the collection.
+func (c *Collection) Len() int { return c.n }
+
+// ItemVersion returns the version of the elements of the collection, as
+// recorded in the archive.
+func (c *Collection) ItemVersion() uint32 { return c.vers }
+
+// More reports whether some elements of the collection are left to decode.
+// More returns false once decoding the collection failed.
+func (c *Collection) More() bool { return c.i < c.n && c.dec.r.err == nil }
+
+// Decode reads the next element of the collection and stores it in the
+// value pointed to by ptr, which is usually reused from one element to the
+// next.
+// Decode returns io.EOF once all the elements have been decoded, and an
+// error matching ErrTypeNotSupported if ptr does not point to a value of
+// the type of the elements.
+//
+// Errors that occur while decoding the element are returned as a
+// *DecodeError, and the decoder can not be used any further.
+func (c *Collection) Decode(ptr interface{}) error {
+	dec := c.dec
+	if dec.r.err != nil {
+		return dec.r.err
+	}
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(ptr)}
+	}
+	if et := c.rt.Elem(); rv.Type().Elem() != et {
+		return fmt.Errorf("%w: %v element decoded into %v", ErrTypeNotSupported, et, rv.Type())
+	}
+	if !c.More() {
+		return io.EOF
+	}
+
+	// decode the element as a value nested in the collection.
+	dec.path = append(dec.path[:0], pathElem{name: typeName(c.rt)})
+	dec.path.push("")
+	dec.path.index(c.i)
+	dec.r.depth = 1
+	err := dec.run(ptr, func() error {
+		if dec.r.poll(c.i); dec.r.err != nil {
+			return dec.r.err
+		}
+		return dec.decode(ptr)
+	})
+	dec.r.depth = 0
+	dec.path = dec.path[:0]
+	c.i++
+	return err
+}
 
+// DecodeMultiIndex reads the next boost::multi_index_container value from
+// its input and stores its elements, in the order of its first index, in the
+// slice pointed to by ptr.
+//
+// The types of the indices of the container must be given, in order.
+func (dec *Decoder) DecodeMultiIndex(ptr interface{}, indices ...boostio.IndexKind) error {
+	if dec.r.err != nil {
+		return dec.r.err
+	}
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return ErrTypeNotSupported
+	}
+	return dec.run(ptr, func() error { return dec.decodeMultiIndex(rv.Elem(), indices) })
+}
+
+func (dec *Decoder) decodeMultiIndex(rv reflect.Value, indices []boostio.IndexKind) error {
+	if rv.Kind() != reflect.Slice || len(indices) == 0 {
+		return ErrTypeNotSupported
+	}
+	var (
+		r       = dec.r
+		rt      = rv.Type()
+		node    = nodeOf(rt)
+		tracked = tracksNodes(indices)
+	)
+	_ = r.ReadTypeDescr(multiIndexOf(rt))
+	n := r.readLen()
+	_ = r.ReadTypeDescr(valueVersionOf(rt.Elem()))
+	if r.err != nil {
+		return r.err
+	}
+
+	rv.Set(reflect.MakeSlice(rt, 0, 0))
+	dec.path.push("")
+	for i := 0; i < n && r.err == nil; i++ {
+		dec.r.poll(i)
+		rv.Set(reflect.Append(rv, reflect.Zero(rt.Elem())))
+		dec.path.index(i)
+		if err := dec.Decode(rv.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+		r.readNodePosition(node, tracked)
+	}
+	dec.path.pop()
+	end := r.readNodePosition(node, tracked) // header node
+
+	for _, k := range indices {
+		if k.Hashed() {
+			_ = r.ReadTypeDescr(bucketsOf(rt))
+		}
+		if !k.Positional() {
+			continue
+		}
+		// pointers to the displaced nodes, up to the header node.
+		for r.err == nil {
+			if r.ReadI16() == nullPointer {
+				continue
+			}
+			if r.ReadU32() == end {
+				break
+			}
+		}
+	}
+	return r.err
+}
+
+// DecodeCArray reads the next C array value from its input and stores it in
+// the array pointed to by ptr.
+//
+// Nested Go arrays are read as the dimensions of a multi-dimensional C
+// array (e.g. [3][4]float64 for a double m[3][4]).
+func (dec *Decoder) DecodeCArray(ptr interface{}) error {
+	if dec.r.err != nil {
+		return dec.r.err
+	}
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr {
+		return ErrTypeNotSupported
+	}
+	return dec.run(ptr, func() error { return dec.decodeCArray(rv.Elem()) })
+}
+
+func (dec *Decoder) decodeCArray(rv reflect.Value) error {
+	if rv.Kind() != reflect.Array {
+		return ErrTypeNotSupported
+	}
+	n := dec.r.readLen()
+	if dec.r.err != nil {
+		return dec.r.err
+	}
+	if n != rv.Len() {
+		return ErrInvalidArrayLen
+	}
+	dec.path.push("")
+	for i := 0; i < n; i++ {
+		dec.r.poll(i)
+		e := rv.Index(i)
+		dec.path.index(i)
+		switch {
+		case isCArray(e.Type()):
+			err := dec.decodeCArray(e)
+			if err != nil {
+				return err
+			}
+		default:
+			if err := dec.Decode(e.Addr().Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	dec.path.pop()
+	return dec.r.err
+}
+
+// DecodeArray reads the next n values, written with make_array, from its
+// input and stores them in the slice or array pointed to by ptr.
+func (dec *Decoder) DecodeArray(ptr interface{}, n int) error {
+	if dec.r.err != nil {
+		return dec.r.err
+	}
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr {
+		return ErrTypeNotSupported
+	}
+	return dec.run(ptr, func() error { return dec.decodeArray(rv.Elem(), n) })
+}
+
+func (dec *Decoder) decodeArray(rv reflect.Value, n int) error {
+	switch rv.Kind() {
+	case reflect.Slice:
+		if n < 0 {
+			return ErrInvalidArrayLen
+		}
+		n = dec.r.checkLen(uint64(n), dec.r.opts.maxLen, "array length")
+		rv.Set(reflect.MakeSlice(rv.Type(), 0, 0))
+		growSlice(rv, n)
+	case reflect.Array:
+		if n != rv.Len() {
+			return ErrInvalidArrayLen
+		}
 	default:
 		return ErrTypeNotSupported
 	}
+	dec.path.push("")
+	for i := 0; i < n && dec.r.err == nil; i++ {
+		dec.r.poll(i)
+		if i == rv.Len() {
+			rv.Set(reflect.Append(rv, reflect.Zero(rv.Type().Elem())))
+		}
+		dec.path.index(i)
+		if err := dec.Decode(rv.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	dec.path.pop()
 	return dec.r.err
 }
+
+func (dec *Decoder) decodeBinaryObject(rv reflect.Value, n int) error {
+	p := dec.r.ReadBinaryObject(n)
+	if dec.r.err != nil {
+		return dec.r.err
+	}
+	if rv.Kind() == reflect.Slice {
+		rv.Set(reflect.MakeSlice(rv.Type(), n, n))
+	}
+	reflect.Copy(rv, reflect.ValueOf(p))
+	return nil
+}
@@ -6,11 +6,20 @@ package binser_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/go-boostio/boostio"
 	"github.com/go-boostio/boostio/binser"
 )
 
@@ -178,7 +187,7 @@ func TestInvalidArchive(t *testing.T) {
 		t.Run("", func(t *testing.T) {
 			dec := binser.NewDecoder(bytes.NewReader(tc.raw))
 			err := dec.Decode(tc.val)
-			if !reflect.DeepEqual(err, tc.err) {
+			if !errors.Is(err, tc.err) {
 				t.Fatalf("got=%#v, want=%#v", err, tc.err)
 			}
 		})
@@ -191,6 +200,60 @@ type animal struct {
 	Tails int8
 }
 
+type book struct {
+	IDs []int32 `boost:",multi_index=ordered_unique|hashed_unique"`
+}
+
+type order struct {
+	ID int32
+}
+
+type queue struct {
+	Orders []order `boost:",multi_index=sequenced|ordered_non_unique"`
+}
+
+type grid struct {
+	Cells  [2][3]int16 `boost:",carray"`
+	Orders [2]order    `boost:",carray"`
+	Dims   [2]int32    `boost:",stdarray"`
+}
+
+type blob struct {
+	Count  int32
+	Values []float32 `boost:",array,len=Count"`
+	Size   uint8
+	Data   []byte  `boost:",binary,len=Size"`
+	Magic  [4]byte `boost:",binary"`
+	Orders []order `boost:",array,len=2"`
+}
+
+type color uint8
+
+const (
+	red color = iota
+	green
+	blue
+)
+
+func (color) EnumValues() []int64 { return []int64{int64(red), int64(green), int64(blue)} }
+
+func (c color) String() string {
+	switch c {
+	case red:
+		return "red"
+	case green:
+		return "green"
+	case blue:
+		return "blue"
+	}
+	return fmt.Sprintf("color(%d)", uint8(c))
+}
+
+type pixel struct {
+	Color color
+	Alpha int8
+}
+
 type manimal struct {
 	name  string
 	legs  int16
@@ -250,11 +313,129 @@ func TestInvalidArray(t *testing.T) {
 	if err == nil {
 		t.Fatalf("expected an error!")
 	}
-	if got, want := err, binser.ErrInvalidArrayLen; !reflect.DeepEqual(got, want) {
+	if got, want := err, binser.ErrInvalidArrayLen; !errors.Is(got, want) {
 		t.Fatalf("got=%#v, want=%#v", got, want)
 	}
 }
 
+func TestInvalidMatrix(t *testing.T) {
+	// (1<<62+1)*4 overflows to 4, the number of elements.
+	raw := archive64([]byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, // matrix class info
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40, // size1
+		0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // size2
+		0x00, 0x00, 0x00, 0x00, 0x00, // unbounded_array class info
+		0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	})
+	var m boostio.Matrix
+	err := binser.NewDecoder(bytes.NewReader(raw)).Decode(&m)
+	if !errors.Is(err, binser.ErrInvalidMatrix) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrInvalidMatrix)
+	}
+}
+
+func TestCArray(t *testing.T) {
+	want := [2][2]float64{{1, 2}, {3, 4}}
+
+	buf := new(bytes.Buffer)
+	err := binser.NewEncoder(buf).EncodeCArray(want)
+	if err != nil {
+		t.Fatalf("could not encode C array: %v", err)
+	}
+	if got, want := buf.Len(), 40+3*8+4*8; got != want {
+		t.Fatalf("invalid archive size: got=%d, want=%d", got, want)
+	}
+
+	raw := buf.Bytes()
+	var got [2][2]float64
+	err = binser.NewDecoder(bytes.NewReader(raw)).DecodeCArray(&got)
+	if err != nil {
+		t.Fatalf("could not decode C array: %v", err)
+	}
+	if got != want {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+
+	var bad [2][3]float64
+	err = binser.NewDecoder(bytes.NewReader(raw)).DecodeCArray(&bad)
+	if !errors.Is(err, binser.ErrInvalidArrayLen) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrInvalidArrayLen)
+	}
+
+	err = binser.NewEncoder(new(bytes.Buffer)).Encode(struct {
+		Values []float64 `boost:",carray"`
+	}{})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestArray(t *testing.T) {
+	want := []animal{{"pet", 4, 1}, {"bird", 2, 1}}
+
+	buf := new(bytes.Buffer)
+	err := binser.NewEncoder(buf).EncodeArray(want)
+	if err != nil {
+		t.Fatalf("could not encode array: %v", err)
+	}
+
+	got := make([]animal, 0)
+	err = binser.NewDecoder(buf).DecodeArray(&got, len(want))
+	if err != nil {
+		t.Fatalf("could not decode array: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+
+	err = binser.NewEncoder(new(bytes.Buffer)).Encode(blob{Count: 2, Values: []float32{1}})
+	if !errors.Is(err, binser.ErrInvalidArrayLen) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrInvalidArrayLen)
+	}
+
+	for _, v := range []interface{}{
+		struct {
+			Values []int32 `boost:",binary,len=2"`
+		}{},
+		struct {
+			Values []int32 `boost:",array"`
+		}{},
+		struct {
+			Value int32 `boost:",len=2"`
+		}{},
+		struct {
+			Values []int32 `boost:",array,len=N"`
+		}{},
+		struct {
+			Values []int32 `boost:",array,len=N"`
+			N      int32
+		}{},
+		struct {
+			boostio.Pair[int32, int32]
+			Values []int32 `boost:",array,len=First"`
+		}{},
+	} {
+		err := binser.NewEncoder(new(bytes.Buffer)).Encode(v)
+		if err == nil {
+			t.Fatalf("%T: expected an error", v)
+		}
+	}
+
+	// the number of elements must be read before the elements.
+	var v struct {
+		Values []int32 `boost:",array,len=N"`
+		N      int32
+	}
+	err = binser.NewDecoder(bytes.NewReader(archive64(make([]byte, 5)))).Decode(&v)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
 func TestDecoderInvalidType(t *testing.T) {
 	f, err := os.Open("testdata/data64.bin")
 	if err != nil {
@@ -269,7 +450,1779 @@ func TestDecoderInvalidType(t *testing.T) {
 	if err == nil {
 		t.Fatalf("expected an error")
 	}
-	if got, want := err, binser.ErrTypeNotSupported; !reflect.DeepEqual(got, want) {
-		t.Fatalf("got=%#v, want=%#v", got, want)
+	var e *binser.InvalidUnmarshalError
+	if !errors.As(err, &e) {
+		t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+	}
+}
+
+// archive64 prepends a 64-bits Boost binary archive header to raw.
+func archive64(raw []byte) []byte {
+	buf := new(bytes.Buffer)
+	w := binser.NewWBuffer(buf)
+	w.WriteString("serialization::archive")
+	w.WriteHeader(binser.Arch64.Header())
+	w.Write(raw)
+	return buf.Bytes()
+}
+
+// archiveTestCases holds hand-written 64-bits archives, as written by C++.
+var archiveTestCases = []struct {
+	name string
+	raw  []byte
+	want []interface{}
+}{
+	{
+		name: "pair",
+		raw: []byte{
+			// std::vector<std::pair<int,std::string>>
+			0, 0, 0, 0, 0, // class info
+			2, 0, 0, 0, 0, 0, 0, 0, // count
+			0, 0, 0, 0, // item_version
+			0, 0, 0, 0, 0, // pair class info
+			1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 'a',
+			2, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 'b', 'c',
+
+			// std::map<int,std::string>
+			0, 0, 0, 0, 0, // class info
+			1, 0, 0, 0, 0, 0, 0, 0, // count
+			0, 0, 0, 0, // item_version
+			0, 0, 0, 0, 0, // pair class info
+			3, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 'x',
+
+			// std::map<int,std::string>: class infos already known.
+			1, 0, 0, 0, 0, 0, 0, 0, // count
+			0, 0, 0, 0, // item_version
+			4, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 'y',
+		},
+		want: []interface{}{
+			[]boostio.Pair[int32, string]{{First: 1, Second: "a"}, {First: 2, Second: "bc"}},
+			map[int32]string{3: "x"},
+			map[int32]string{4: "y"},
+		},
+	},
+	{
+		name: "bitset",
+		raw: []byte{
+			// std::bitset<10>
+			0, 0, 0, 0, 0, // class info
+			10, 0, 0, 0, 0, 0, 0, 0,
+			'1', '0', '0', '0', '0', '0', '0', '1', '0', '1',
+
+			// boost::dynamic_bitset<>
+			0, 0, 0, 0, 0, // class info
+			70, 0, 0, 0, 0, 0, 0, 0, // m_num_bits
+			2, 0, 0, 0, 0, 0, 0, 0, // m_bits count
+			0x01, 0, 0, 0, 0, 0, 0, 0x80,
+			0x21, 0, 0, 0, 0, 0, 0, 0,
+		},
+		want: []interface{}{
+			newBitset("1000000101"),
+			*boostio.NewDynamicBitset(70, 0x8000000000000001, 0x21),
+		},
+	},
+	{
+		name: "wstring",
+		raw: []byte{
+			3, 0, 0, 0, 0, 0, 0, 0,
+			'h', 0, 0, 0, 0xe9, 0, 0, 0, 0x00, 0xf6, 0x01, 0,
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+		want: []interface{}{
+			boostio.WString("h\u00e9\U0001f600"),
+			boostio.WString(""),
+		},
+	},
+	{
+		name: "long-double",
+		raw: []byte{
+			0x35, 0xc2, 0x68, 0x21, 0xa2, 0xda, 0x0f, 0xc9, 0x00, 0x40,
+			0, 0, 0, 0, 0, 0, // padding
+			0, 0, 0, 0, 0, 0, 0, 0x80, 0xff, 0xbf,
+			0, 0, 0, 0, 0, 0, // padding
+		},
+		want: []interface{}{
+			boostio.LongDouble{SignExp: 0x4000, Mantissa: 0xc90fdaa22168c235},
+			boostio.NewLongDouble(-1),
+		},
+	},
+	{
+		name: "multiprecision",
+		raw: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x00, // number<cpp_int_backend> class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // cpp_int_backend class info
+			0x01,                                           // sign
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // limbs
+			0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+
+			0x00,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+
+			0x00, 0x00, 0x00, 0x00, 0x00, // rational<cpp_int> class info
+			0x01,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+
+			0x00, 0x00, 0x00, 0x00, 0x00, // number<cpp_dec_float<50>> class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // cpp_dec_float<50> class info
+			0x01, 0x00, 0x00, 0x00, // data
+			0x80, 0xf0, 0xfa, 0x02,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, // exp
+			0x00,                   // neg
+			0x00, 0x00, 0x00, 0x00, // fpclass
+			0x0a, 0x00, 0x00, 0x00, // prec_elem
+		},
+		want: []interface{}{
+			newBigInt("-18446744073709551621"), // -(2^64+5)
+			big.NewInt(7),
+			big.NewRat(-3, 4),
+			big.NewFloat(1.5),
+		},
+	},
+	{
+		name: "date-time",
+		raw: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x00, // date class info
+			0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			'2', '0', '0', '2', '0', '1', '3', '1',
+
+			0x00, 0x00, 0x00, 0x00, 0x00, // ptime class info
+			0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			'2', '0', '1', '8', '0', '3', '0', '4',
+			0x00, 0x01, 0x00, 0x00, 0x00, // time_duration class info (version 1)
+			0x00,                                           // is_special
+			0x0a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // hours
+			0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // minutes
+			0x1e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // seconds
+			0x40, 0xe2, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, // fractional seconds
+
+			0x00,
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0xe0, 0x5e, 0xf8, 0xff, 0xff, 0xff, 0xff, 0xff,
+
+			0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			'+', 'i', 'n', 'f', 'i', 'n', 'i', 't', 'y',
+
+			0x01,
+			0x0f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			'n', 'o', 't', '-', 'a', '-', 'd', 'a', 't', 'e', '-', 't', 'i', 'm', 'e',
+		},
+		want: []interface{}{
+			boostio.NewDate(2002, time.January, 31),
+			time.Date(2018, time.March, 4, 10, 20, 30, 123456000, time.UTC),
+			-(time.Hour + 500*time.Millisecond),
+			boostio.PTime{Special: boostio.PosInfinity},
+			boostio.TimeDuration{Special: boostio.NotADateTime},
+		},
+	},
+	{
+		name: "uuid",
+		raw: []byte{
+			0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+			0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+
+			0x00, 0x00, 0x00, 0x00, 0x00, // record class info
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0x2a, 0x00, 0x00, 0x00,
+		},
+		want: []interface{}{
+			newUUID("01234567-89ab-cdef-0123-456789abcdef"),
+			struct {
+				ID boostio.UUID
+				N  int32
+			}{newUUID("ffffffff-ffff-ffff-ffff-ffffffffffff"), 42},
+		},
+	},
+	{
+		name: "ublas",
+		raw: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x00, // vector class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // unbounded_array class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc0,
+
+			0x00, 0x00, 0x00, 0x00, 0x00, // matrix<row_major> class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // size1
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // size2
+			0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x40,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x40,
+
+			0x00, 0x00, 0x00, 0x00, 0x00, // matrix<column_major> class info
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40,
+		},
+		want: []interface{}{
+			boostio.Vector{1.5, -2},
+			*boostio.NewMatrix(2, 2, []float64{1, 2, 3, 4}),
+			boostio.Matrix{Rows: 1, Cols: 2, ColMajor: true, Data: []float64{1, 2}},
+		},
+	},
+	{
+		name: "ptree",
+		raw: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x00, // ptree class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x00, 0x00, 0x00, 0x00, // item_version
+			0x00, 0x00, 0x00, 0x00, 0x00, // pair<const string, ptree> class info
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'a',
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, '1',
+
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'a',
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'b',
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, '2',
+
+			0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'r', 'o', 'o', 't',
+		},
+		want: []interface{}{
+			boostio.PTree{
+				Data: "root",
+				Children: []boostio.Pair[string, boostio.PTree]{
+					{First: "a", Second: boostio.PTree{Data: "1"}},
+					{First: "a", Second: boostio.PTree{
+						Data: "2",
+						Children: []boostio.Pair[string, boostio.PTree]{
+							{First: "b", Second: boostio.PTree{}},
+						},
+					}},
+				},
+			},
+		},
+	},
+	{
+		name: "histogram",
+		raw: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x00, // histogram<..., dense_storage<int>> class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // std::vector<axis::variant<...>> class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x00, 0x00, 0x00, 0x00, // item_version
+			0x00, 0x00, 0x00, 0x00, 0x00, // axis::variant<...> class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // variant_proxy class info
+			0x00, 0x00, 0x00, 0x00, // which
+			0x00, 0x00, 0x00, 0x00, 0x00, // axis::regular<> class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // axis::transform::id class info
+			0x02, 0x00, 0x00, 0x00, // size
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'x',
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // min
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xe0, 0x3f, // delta
+			0x04, 0x00, 0x00, 0x00, // which
+			0x00, 0x00, 0x00, 0x00, 0x00, // axis::category<std::string> class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // std::vector<std::string> class info
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'a',
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, // dense_storage<int> class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // vector_impl<std::vector<int>> class info
+			0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00,
+			0x02, 0x00, 0x00, 0x00,
+			0x03, 0x00, 0x00, 0x00,
+
+			0x00, 0x00, 0x00, 0x00, 0x00, // histogram<..., weight_storage> class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, // which
+			0x00, 0x00, 0x00, 0x00, 0x00, // axis::variable<> class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'v',
+			0x00, 0x00, 0x00, 0x00, // which
+			0x00, 0x00, 0x00, 0x00, 0x00, // axis::integer<> class info
+			0x01, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0xff, 0xff, 0xff, 0xff,
+			0x00, 0x00, 0x00, 0x00, 0x00, // weight_storage class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // vector_impl<std::vector<weighted_sum<>>> class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // std::vector<weighted_sum<>> class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, // weighted_sum<> class info
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x40,
+		},
+		want: []interface{}{
+			boostio.Histogram{
+				Axes: []boostio.Axis{
+					boostio.RegularAxis{Size: 2, Meta: "x", Min: 0, Delta: 0.5},
+					boostio.StrCategoryAxis{Values: []string{"a"}},
+				},
+				Counts: []int32{0, 1, 2, 3},
+			},
+			boostio.Histogram{
+				Variant: []boostio.AxisKind{boostio.IntegerAxisKind, boostio.VariableAxisKind},
+				Axes: []boostio.Axis{
+					boostio.VariableAxis{Edges: []float64{0, 1}, Meta: "v"},
+					boostio.IntegerAxis{Size: 1, Min: -1},
+				},
+				Weighted: true,
+				Weights:  []boostio.WeightedSum{{SumOfWeights: 1, SumOfWeightsSquared: 1}, {SumOfWeights: 2, SumOfWeightsSquared: 4}},
+			},
+		},
+	},
+	{
+		name: "multi-index",
+		raw: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x00, // book class info
+			0x00, 0x02, 0x00, 0x00, 0x00, // multi_index_container class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x00, 0x00, 0x00, 0x00, 0x00, // value_version class info
+			0x03, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, // node class info (untracked)
+			0x01, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, // bucket_array class info
+
+			0x00, 0x00, 0x00, 0x00, 0x00, // queue class info
+			0x00, 0x02, 0x00, 0x00, 0x00, // multi_index_container class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x00, 0x00, 0x00, 0x00, 0x00, // value_version class info
+			0x00, 0x00, 0x00, 0x00, 0x00, // order class info
+			0x07, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, 0x00, // node class info (tracked)
+			0x00, 0x00, 0x00, 0x00, // object id
+			0x08, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00,
+			0x02, 0x00, 0x00, 0x00, // header node
+			0x09, 0x00, 0x02, 0x00, 0x00, 0x00, // sequenced: pointer to header node
+			0x09, 0x00, 0x02, 0x00, 0x00, 0x00, // ordered_non_unique: pointer to header node
+		},
+		want: []interface{}{
+			book{IDs: []int32{3, 1}},
+			queue{Orders: []order{{ID: 7}, {ID: 8}}},
+		},
+	},
+	{
+		name: "enum",
+		raw: []byte{
+			0x02, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, // pixel class info
+			0x01, 0x00, 0x00, 0x00,
+			0xff,
+			0x00, 0x00, 0x00, 0x00, 0x00, // std::vector<color> class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x00, 0x00, 0x00, 0x00, // item_version
+			0x00, 0x00, 0x00, 0x00,
+			0x02, 0x00, 0x00, 0x00,
+		},
+		want: []interface{}{
+			blue,
+			pixel{Color: green, Alpha: -1},
+			[]color{red, blue},
+		},
+	},
+	{
+		name: "c-array",
+		raw: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x00, // grid class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x01, 0x00, 0x02, 0x00, 0x03, 0x00,
+			0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x04, 0x00, 0x05, 0x00, 0x06, 0x00,
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x00, 0x00, 0x00, 0x00, 0x00, // order class info
+			0x07, 0x00, 0x00, 0x00,
+			0x08, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, // std::array<int, 2> class info
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // count
+			0x09, 0x00, 0x00, 0x00,
+			0x0a, 0x00, 0x00, 0x00,
+		},
+		want: []interface{}{
+			grid{
+				Cells:  [2][3]int16{{1, 2, 3}, {4, 5, 6}},
+				Orders: [2]order{{ID: 7}, {ID: 8}},
+				Dims:   [2]int32{9, 10},
+			},
+		},
+	},
+	{
+		name: "headerless-array",
+		raw: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x00, // blob class info
+			0x03, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x80, 0x3f,
+			0x00, 0x00, 0x00, 0x40,
+			0x00, 0x00, 0x40, 0x40,
+			0x02,
+			0xca, 0xfe,
+			'B', 'O', 'O', 'S',
+			0x00, 0x00, 0x00, 0x00, 0x00, // order class info
+			0x07, 0x00, 0x00, 0x00,
+			0x08, 0x00, 0x00, 0x00,
+		},
+		want: []interface{}{
+			blob{
+				Count:  3,
+				Values: []float32{1, 2, 3},
+				Size:   2,
+				Data:   []byte{0xca, 0xfe},
+				Magic:  [4]byte{'B', 'O', 'O', 'S'},
+				Orders: []order{{ID: 7}, {ID: 8}},
+			},
+		},
+	},
+}
+
+// equal reports whether got and want are deeply equal.
+// math/big values are compared by value.
+func equal(got, want interface{}) bool {
+	switch want := want.(type) {
+	case *big.Int:
+		return want.Cmp(got.(*big.Int)) == 0
+	case *big.Float:
+		return want.Cmp(got.(*big.Float)) == 0
+	case *big.Rat:
+		return want.Cmp(got.(*big.Rat)) == 0
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func newBigInt(s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic(fmt.Errorf("invalid integer %q", s))
+	}
+	return v
+}
+
+func newUUID(s string) boostio.UUID {
+	u, err := boostio.ParseUUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func newBitset(s string) boostio.Bitset {
+	b, err := boostio.ParseBitset(s)
+	if err != nil {
+		panic(err)
+	}
+	return *b
+}
+
+// newValue returns a value, ready to be decoded, of the same type than v.
+func newValue(v interface{}) reflect.Value {
+	rv := reflect.New(reflect.TypeOf(v)).Elem()
+	switch v := v.(type) {
+	case boostio.Bitset:
+		rv.Set(reflect.ValueOf(*boostio.NewBitset(v.Len())))
+	case boostio.Matrix:
+		rv.Set(reflect.ValueOf(boostio.Matrix{ColMajor: v.ColMajor}))
+	case boostio.Histogram:
+		rv.Set(reflect.ValueOf(boostio.Histogram{Variant: v.Variant, Weighted: v.Weighted}))
+	}
+	if rv.Kind() == reflect.Map {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+	return rv
+}
+
+func TestDecoderArchive(t *testing.T) {
+	for _, tc := range archiveTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := binser.NewDecoder(bytes.NewReader(archive64(tc.raw)))
+			for _, want := range tc.want {
+				rv := newValue(want)
+				err := dec.Decode(rv.Addr().Interface())
+				if err != nil {
+					t.Fatalf("could not decode %T: %v", want, err)
+				}
+				if got := rv.Interface(); !equal(got, want) {
+					t.Fatalf("got=%#v\nwant=%#v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWString(t *testing.T) {
+	const want = "h\u00e9llo \U0001f600"
+	for _, tc := range []struct {
+		name string
+		opts []binser.Option
+		raw  []byte
+	}{
+		{
+			name: "utf16",
+			opts: []binser.Option{binser.WithWCharSize(2)},
+			raw: []byte{
+				8, 0, 0, 0, 0, 0, 0, 0,
+				'h', 0, 0xe9, 0, 'l', 0, 'l', 0, 'o', 0, ' ', 0,
+				0x3d, 0xd8, 0x00, 0xde,
+			},
+		},
+		{
+			name: "utf32",
+			opts: []binser.Option{binser.WithWCharSize(4)},
+			raw: []byte{
+				7, 0, 0, 0, 0, 0, 0, 0,
+				'h', 0, 0, 0, 0xe9, 0, 0, 0, 'l', 0, 0, 0, 'l', 0, 0, 0,
+				'o', 0, 0, 0, ' ', 0, 0, 0, 0x00, 0xf6, 0x01, 0,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := binser.NewWBuffer(buf, tc.opts...)
+			err := w.WriteWString(want)
+			if err != nil {
+				t.Fatalf("could not write wstring: %v", err)
+			}
+			if got, want := buf.Bytes(), tc.raw; !bytes.Equal(got, want) {
+				t.Fatalf("invalid wstring:\ngot:\n%s\nwant:\n%s", hex.Dump(got), hex.Dump(want))
+			}
+
+			r := binser.NewRBuffer(bytes.NewReader(tc.raw), tc.opts...)
+			if got := r.ReadWString(); got != want {
+				t.Fatalf("got=%q, want=%q (err=%v)", got, want, r.Err())
+			}
+		})
+	}
+}
+
+func TestInvalidWString(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []binser.Option
+		raw  []byte
+	}{
+		{
+			name: "utf16-lone-high-surrogate",
+			opts: []binser.Option{binser.WithWCharSize(2)},
+			raw:  []byte{1, 0, 0, 0, 0, 0, 0, 0, 0x3d, 0xd8},
+		},
+		{
+			name: "utf16-lone-low-surrogate",
+			opts: []binser.Option{binser.WithWCharSize(2)},
+			raw:  []byte{2, 0, 0, 0, 0, 0, 0, 0, 0x00, 0xde, 'a', 0},
+		},
+		{
+			name: "utf32-out-of-range",
+			opts: []binser.Option{binser.WithWCharSize(4)},
+			raw:  []byte{1, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x00, 0x11, 0x00},
+		},
+		{
+			name: "utf32-surrogate",
+			opts: []binser.Option{binser.WithWCharSize(4)},
+			raw:  []byte{1, 0, 0, 0, 0, 0, 0, 0, 0x00, 0xd8, 0x00, 0x00},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := binser.NewRBuffer(bytes.NewReader(tc.raw), tc.opts...)
+			_ = r.ReadWString()
+			if got, want := r.Err(), binser.ErrInvalidCodePoint; got != want {
+				t.Fatalf("got=%v, want=%v", got, want)
+			}
+		})
+	}
+
+	w := binser.NewWBuffer(new(bytes.Buffer))
+	err := w.WriteWString("\xff")
+	if got, want := err, binser.ErrInvalidCodePoint; got != want {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestLongDoubleSize(t *testing.T) {
+	want := boostio.LongDouble{SignExp: 0x4000, Mantissa: 0xc90fdaa22168c235}
+	for _, tc := range []struct {
+		size int
+		raw  []byte
+		want boostio.LongDouble
+	}{
+		{
+			size: 8,
+			raw:  []byte{0x18, 0x2d, 0x44, 0x54, 0xfb, 0x21, 0x09, 0x40},
+			want: boostio.NewLongDouble(math.Pi),
+		},
+		{
+			size: 12,
+			raw: []byte{
+				0x35, 0xc2, 0x68, 0x21, 0xa2, 0xda, 0x0f, 0xc9, 0x00, 0x40,
+				0, 0,
+			},
+			want: want,
+		},
+		{
+			size: 16,
+			raw: []byte{
+				0x35, 0xc2, 0x68, 0x21, 0xa2, 0xda, 0x0f, 0xc9, 0x00, 0x40,
+				0, 0, 0, 0, 0, 0,
+			},
+			want: want,
+		},
+	} {
+		t.Run(fmt.Sprintf("%d", tc.size), func(t *testing.T) {
+			opt := binser.WithLongDoubleSize(tc.size)
+
+			buf := new(bytes.Buffer)
+			w := binser.NewWBuffer(buf, opt)
+			err := w.WriteLongDouble(want)
+			if err != nil {
+				t.Fatalf("could not write long double: %v", err)
+			}
+			if got, want := buf.Bytes(), tc.raw; !bytes.Equal(got, want) {
+				t.Fatalf("invalid long double:\ngot:\n%s\nwant:\n%s", hex.Dump(got), hex.Dump(want))
+			}
+
+			r := binser.NewRBuffer(bytes.NewReader(tc.raw), opt)
+			if got, want := r.ReadLongDouble(), tc.want; got != want || r.Err() != nil {
+				t.Fatalf("got=%#v, want=%#v (err=%v)", got, want, r.Err())
+			}
+		})
+	}
+}
+
+func TestDecoderDateTime(t *testing.T) {
+	raw := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, // ptime class info
+		0x00, 0x00, 0x00, 0x00, 0x00, // date class info
+		0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		'2', '0', '1', '8', '0', '3', '0', '4',
+		0x00, 0x00, 0x00, 0x00, 0x00, // time_duration class info (version 0)
+		0x00,
+		0xfe, 0xff, 0xff, 0xff, // hours
+		0xfd, 0xff, 0xff, 0xff, // minutes
+		0x00, 0x00, 0x00, 0x00, // seconds
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+
+		0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		'-', 'i', 'n', 'f', 'i', 'n', 'i', 't', 'y',
+	}
+
+	dec := binser.NewDecoder(bytes.NewReader(archive64(raw)))
+
+	var v time.Time
+	err := dec.Decode(&v)
+	if err != nil {
+		t.Fatalf("could not decode ptime: %v", err)
+	}
+	if got, want := v, time.Date(2018, time.March, 3, 21, 57, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+
+	err = dec.Decode(&v)
+	if got, want := err, binser.ErrSpecialValue; !errors.Is(got, want) {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+}
+
+func TestMultiIndex(t *testing.T) {
+	want := []order{{ID: 3}, {ID: 1}, {ID: 2}}
+	indices := []boostio.IndexKind{boostio.RandomAccess, boostio.HashedNonUnique}
+
+	buf := new(bytes.Buffer)
+	enc := binser.NewEncoder(buf)
+	err := enc.EncodeMultiIndex(want, indices...)
+	if err != nil {
+		t.Fatalf("could not encode multi_index_container: %v", err)
+	}
+	err = enc.Encode(int32(42))
+	if err != nil {
+		t.Fatalf("could not encode trailing value: %v", err)
+	}
+
+	dec := binser.NewDecoder(buf)
+	var got []order
+	err = dec.DecodeMultiIndex(&got, indices...)
+	if err != nil {
+		t.Fatalf("could not decode multi_index_container: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+
+	var v int32
+	err = dec.Decode(&v)
+	if err != nil || v != 42 {
+		t.Fatalf("invalid trailing value: got=%d (err=%v)", v, err)
+	}
+
+	// builtin types, with no class information, do not use up class IDs.
+	tracked := []boostio.IndexKind{boostio.Sequenced, boostio.OrderedUnique}
+	var raw [2][]byte
+	for i, v := range []interface{}{int32(0), []int32{1, 2}} {
+		buf := new(bytes.Buffer)
+		enc := binser.NewEncoder(buf)
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("could not encode %T: %v", v, err)
+		}
+		n := buf.Len()
+		if err := enc.EncodeMultiIndex(want, tracked...); err != nil {
+			t.Fatalf("could not encode multi_index_container: %v", err)
+		}
+		raw[i] = buf.Bytes()[n:]
+	}
+	if !bytes.Equal(raw[0], raw[1]) {
+		t.Fatalf("invalid multi_index_container after builtin vector:\ngot= %x\nwant=%x", raw[1], raw[0])
+	}
+
+	err = binser.NewEncoder(new(bytes.Buffer)).Encode(struct {
+		IDs []int32 `boost:",multi_index=ordered_unique|ranked_unique"`
+	}{})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestEnum(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := binser.NewEncoder(buf)
+	enc.Header = binser.Header{
+		Version: boostio.Version,
+		Flags:   binary.LittleEndian.Uint64([]byte{2, 8, 4, 8, 1, 0, 0, 0}),
+	}
+	err := enc.Encode(green)
+	if err != nil {
+		t.Fatalf("could not encode enum: %v", err)
+	}
+	if got, want := buf.Len(), 40+2; got != want {
+		t.Fatalf("invalid archive size: got=%d, want=%d", got, want)
+	}
+
+	var c color
+	err = binser.NewDecoder(buf).Decode(&c)
+	if err != nil {
+		t.Fatalf("could not decode enum: %v", err)
+	}
+	if c != green {
+		t.Fatalf("got=%v, want=%v", c, green)
+	}
+
+	err = binser.NewEncoder(new(bytes.Buffer)).Encode(color(7))
+	if !errors.Is(err, binser.ErrInvalidEnum) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrInvalidEnum)
+	}
+
+	for _, raw := range [][]byte{
+		{0x07, 0x00, 0x00, 0x00},
+		{0xff, 0xff, 0xff, 0xff},
+		{0x00, 0x01, 0x00, 0x00},
+	} {
+		err = binser.NewDecoder(bytes.NewReader(archive64(raw))).Decode(&c)
+		if !errors.Is(err, binser.ErrInvalidEnum) {
+			t.Fatalf("% x: invalid error: got=%v, want=%v", raw, err, binser.ErrInvalidEnum)
+		}
+	}
+}
+
+func TestVectorBool(t *testing.T) {
+	for _, tc := range []struct {
+		version uint16
+		size    int
+	}{
+		{version: 5, size: 4 + 3},
+		{version: boostio.Version, size: 8 + 3},
+	} {
+		t.Run(fmt.Sprintf("v%d", tc.version), func(t *testing.T) {
+			want := []bool{true, false, true}
+			hdr := binser.Arch64.Header()
+			hdr.Version = tc.version
+
+			buf := new(bytes.Buffer)
+			enc := binser.NewEncoder(buf)
+			enc.Header = hdr
+			err := enc.Encode(want)
+			if err != nil {
+				t.Fatalf("could not encode vector<bool>: %v", err)
+			}
+			if got, want := buf.Len(), 40+tc.size; got != want {
+				t.Fatalf("invalid archive size: got=%d, want=%d", got, want)
+			}
+
+			var got []bool
+			err = binser.NewDecoder(buf).Decode(&got)
+			if err != nil {
+				t.Fatalf("could not decode vector<bool>: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got=%v, want=%v", got, want)
+			}
+		})
+	}
+}
+
+func TestCollectionSize32(t *testing.T) {
+	// archives up to library version 5 hold 32-bits collection sizes, but
+	// size_t string sizes.
+	hdr := binser.Arch64.Header()
+	hdr.Version = 5
+	buf := new(bytes.Buffer)
+	w := binser.NewWBuffer(buf)
+	w.WriteString("serialization::archive")
+	w.WriteHeader(hdr)
+	w.Write([]byte{
+		0x02, 0x00, 0x00, 0x00, // count
+		0x01, 0x00, 0x00, 0x00,
+		0x02, 0x00, 0x00, 0x00,
+
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'a', 'b',
+
+		0x00, 0x00, 0x00, 0x00, 0x00, // map class info
+		0x01, 0x00, 0x00, 0x00, // count
+		0x00, 0x00, 0x00, 0x00, // item_version
+		0x00, 0x00, 0x00, 0x00, 0x00, // pair class info
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'a',
+		0x01, 0x00, 0x00, 0x00,
+	})
+	raw := buf.Bytes()
+	values := []interface{}{[]int32{1, 2}, "ab", map[string]int32{"a": 1}}
+
+	dec := binser.NewDecoder(bytes.NewReader(raw))
+	for _, want := range values {
+		got := reflect.New(reflect.TypeOf(want))
+		if err := dec.Decode(got.Interface()); err != nil {
+			t.Fatalf("could not decode %T: %v", want, err)
+		}
+		if got := got.Elem().Interface(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got=%v, want=%v", got, want)
+		}
+	}
+	if err := dec.Finish(); err != nil {
+		t.Fatalf("could not finish: %v", err)
+	}
+
+	buf = new(bytes.Buffer)
+	enc := binser.NewEncoder(buf)
+	enc.Header = hdr
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("could not encode %T: %v", v, err)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), raw) {
+		t.Fatalf("invalid archive:\ngot:\n%s\nwant:\n%s", hex.Dump(buf.Bytes()), hex.Dump(raw))
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	encode := func(v interface{}) []byte {
+		buf := new(bytes.Buffer)
+		err := binser.NewEncoder(buf).Encode(v)
+		if err != nil {
+			t.Fatalf("could not encode %T: %v", v, err)
+		}
+		return buf.Bytes()
+	}
+	huge := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00} // 1<<40
+
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+		ptr  interface{}
+		opts []binser.Option
+		want error
+	}{
+		{
+			name: "string-truncated",
+			raw:  archive64(append(huge, "abc"...)),
+			ptr:  new(string),
+			want: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "slice-truncated",
+			raw:  archive64(append(huge, 0x01, 0x00, 0x00, 0x00)),
+			ptr:  new([]int32),
+			want: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "struct-truncated",
+			raw:  encode(animal{"pet", 4, 1})[:50],
+			ptr:  new(animal),
+			want: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "max-string-len",
+			raw:  encode("abc"),
+			ptr:  new(string),
+			opts: []binser.Option{binser.WithMaxStringLen(2)},
+			want: binser.ErrLimitExceeded,
+		},
+		{
+			name: "max-string-len-ok",
+			raw:  encode("abc"),
+			ptr:  new(string),
+			opts: []binser.Option{binser.WithMaxStringLen(3)},
+		},
+		{
+			name: "max-collection-len",
+			raw:  encode([]int32{1, 2, 3}),
+			ptr:  new([]int32),
+			opts: []binser.Option{binser.WithMaxCollectionLen(2)},
+			want: binser.ErrLimitExceeded,
+		},
+		{
+			name: "max-depth",
+			raw:  encode([][]int32{{1}, {2}}),
+			ptr:  new([][]int32),
+			opts: []binser.Option{binser.WithMaxDepth(2)},
+			want: binser.ErrLimitExceeded,
+		},
+		{
+			name: "max-depth-ok",
+			raw:  encode([][]int32{{1}, {2}}),
+			ptr:  new([][]int32),
+			opts: []binser.Option{binser.WithMaxDepth(3)},
+		},
+		{
+			name: "max-bytes",
+			raw:  encode([]int32{1, 2, 3}),
+			ptr:  new([]int32),
+			opts: []binser.Option{binser.WithMaxBytes(59)},
+			want: binser.ErrLimitExceeded,
+		},
+		{
+			name: "max-bytes-ok",
+			raw:  encode([]int32{1, 2, 3}),
+			ptr:  new([]int32),
+			opts: []binser.Option{binser.WithMaxBytes(60)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := binser.NewDecoder(bytes.NewReader(tc.raw), tc.opts...)
+			err := dec.Decode(tc.ptr)
+			switch {
+			case tc.want == nil && err != nil:
+				t.Fatalf("could not decode: %v", err)
+			case !errors.Is(err, tc.want):
+				t.Fatalf("invalid error: got=%v, want=%v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecoderErrorPath(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := binser.NewEncoder(buf).Encode(map[string][]pixel{
+		"a": {{red, 1}, {blue, 2}},
+	})
+	if err != nil {
+		t.Fatalf("could not encode: %v", err)
+	}
+	raw := buf.Bytes()
+	raw = raw[:len(raw)-1]
+
+	dec := binser.NewDecoder(bytes.NewReader(raw))
+	v := make(map[string][]pixel)
+	err = dec.Decode(&v)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, io.ErrUnexpectedEOF)
+	}
+
+	var e *binser.DecodeError
+	if !errors.As(err, &e) {
+		t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+	}
+	if got, want := e.Path, "map[string][]binser_test.pixel[0].second[1].Alpha"; got != want {
+		t.Fatalf("invalid path: got=%q, want=%q", got, want)
+	}
+	if got, want := e.Offset, int64(len(raw)); got != want {
+		t.Fatalf("invalid offset: got=%d, want=%d", got, want)
+	}
+	if got, want := dec.InputOffset(), int64(len(raw)); got != want {
+		t.Fatalf("invalid input offset: got=%d, want=%d", got, want)
+	}
+}
+
+func TestDecoderNestedError(t *testing.T) {
+	type item struct {
+		ID   int32
+		Size int // not supported.
+	}
+
+	buf := new(bytes.Buffer)
+	err := binser.NewEncoder(buf).Encode([]order{{1}, {2}})
+	if err != nil {
+		t.Fatalf("could not encode: %v", err)
+	}
+
+	dec := binser.NewDecoder(bytes.NewReader(buf.Bytes()))
+	var v []item
+	err = dec.Decode(&v)
+	if !errors.Is(err, binser.ErrTypeNotSupported) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrTypeNotSupported)
+	}
+	var e *binser.DecodeError
+	if !errors.As(err, &e) {
+		t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+	}
+	if got, want := e.Path, "[]binser_test.item[0].Size"; got != want {
+		t.Fatalf("invalid path: got=%q, want=%q", got, want)
+	}
+
+	if got := dec.Decode(new(int32)); got != err {
+		t.Fatalf("decoder not in failed state: got=%v, want=%v", got, err)
+	}
+
+	buf.Reset()
+	err = binser.NewEncoder(buf).Encode([2]int32{1, 2})
+	if err != nil {
+		t.Fatalf("could not encode: %v", err)
+	}
+
+	// errors before any input is read leave the decoder usable.
+	dec = binser.NewDecoder(bytes.NewReader(buf.Bytes()))
+	err = dec.Decode(new(int))
+	if !errors.Is(err, binser.ErrTypeNotSupported) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrTypeNotSupported)
+	}
+
+	// errors of a partly read top-level value are sticky.
+	err = dec.Decode(new([3]int32))
+	if !errors.Is(err, binser.ErrInvalidArrayLen) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrInvalidArrayLen)
+	}
+	if got := dec.Decode(new(int32)); got != err {
+		t.Fatalf("decoder not in failed state: got=%v, want=%v", got, err)
+	}
+}
+
+func TestDecoderStrict(t *testing.T) {
+	for _, fname := range []string{
+		"testdata/data64.bin",
+		"testdata/data32.bin",
+	} {
+		t.Run(fname, func(t *testing.T) {
+			raw, err := os.ReadFile(fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dec := binser.NewDecoder(bytes.NewReader(raw), binser.WithStrict())
+			for _, tc := range typeTestCases {
+				ptr := reflect.New(reflect.TypeOf(tc.want))
+				err := dec.Decode(ptr.Interface())
+				if err != nil {
+					t.Fatalf("could not read %q: %v", tc.name, err)
+				}
+			}
+			if err := dec.Finish(); err != nil {
+				t.Fatalf("could not finish: %v", err)
+			}
+		})
+	}
+
+	for _, tc := range archiveTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := binser.NewDecoder(bytes.NewReader(archive64(tc.raw)), binser.WithStrict())
+			for _, want := range tc.want {
+				rv := newValue(want)
+				err := dec.Decode(rv.Addr().Interface())
+				if err != nil {
+					t.Fatalf("could not decode %T: %v", want, err)
+				}
+			}
+			if err := dec.Finish(); err != nil {
+				t.Fatalf("could not finish: %v", err)
+			}
+		})
+	}
+
+	encode := func(v interface{}) []byte {
+		buf := new(bytes.Buffer)
+		err := binser.NewEncoder(buf).Encode(v)
+		if err != nil {
+			t.Fatalf("could not encode %T: %v", v, err)
+		}
+		return buf.Bytes()
+	}
+	patch := func(raw []byte, i int, v byte) []byte {
+		raw = append([]byte(nil), raw...)
+		raw[i] = v
+		return raw
+	}
+	const (
+		version  = 30 // offset of the archive version.
+		flags    = 32 // offset of the archive flags.
+		tracking = 40 // offset of the tracking level of the first class.
+		class    = 41 // offset of the version of the first class.
+		item     = 53 // offset of the item_version of a top-level slice.
+	)
+
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+		ptr  interface{}
+		want error // error in strict mode.
+	}{
+		{
+			name: "ok",
+			raw:  encode(animal{"pet", 4, 1}),
+			ptr:  new(animal),
+		},
+		{
+			name: "time-durations",
+			raw:  encode([]boostio.TimeDuration{{Duration: time.Second}}),
+			ptr:  new([]boostio.TimeDuration),
+		},
+		{
+			name: "archive-version",
+			raw:  patch(encode(int32(1)), version, 0x42),
+			ptr:  new(int32),
+			want: binser.ErrInvalidHeader,
+		},
+		{
+			name: "archive-flags",
+			raw:  patch(encode(int32(1)), flags+4, 0),
+			ptr:  new(int32),
+			want: binser.ErrInvalidHeader,
+		},
+		{
+			name: "user-class-version",
+			raw:  patch(encode(animal{"pet", 4, 1}), class, 1),
+			ptr:  new(animal),
+		},
+		{
+			name: "class-version",
+			raw:  patch(encode([]order{{1}}), class, 1),
+			ptr:  new([]order),
+			want: binser.ErrInvalidTypeDescr,
+		},
+		{
+			name: "tracking-level",
+			raw:  patch(encode(animal{"pet", 4, 1}), tracking, 2),
+			ptr:  new(animal),
+		},
+		{
+			name: "item-version",
+			raw:  patch(encode([]boostio.TimeDuration{{}}), item, 0),
+			ptr:  new([]boostio.TimeDuration),
+			want: binser.ErrItemVersion,
+		},
+		{
+			name: "user-item-version",
+			raw:  patch(encode([]order{{1}}), item, 1),
+			ptr:  new([]order),
+			want: binser.ErrInvalidTypeDescr,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ptr := reflect.New(reflect.TypeOf(tc.ptr).Elem()).Interface()
+			err := binser.NewDecoder(bytes.NewReader(tc.raw)).Decode(ptr)
+			if err != nil {
+				t.Fatalf("could not decode in lax mode: %v", err)
+			}
+
+			dec := binser.NewDecoder(bytes.NewReader(tc.raw), binser.WithStrict())
+			err = dec.Decode(tc.ptr)
+			if err == nil {
+				err = dec.Finish()
+			}
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("invalid error: got=%v, want=%v", err, tc.want)
+			}
+			if tc.want == nil && !reflect.DeepEqual(tc.ptr, ptr) {
+				t.Fatalf("invalid value: got=%#v, want=%#v", tc.ptr, ptr)
+			}
+		})
+	}
+}
+
+func TestDecoderFinish(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := binser.NewEncoder(buf)
+	for _, v := range []int32{1, 2} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("could not encode: %v", err)
+		}
+	}
+	raw := buf.Bytes()
+
+	for _, tc := range []struct {
+		name string
+		n    int // number of values decoded.
+		opts []binser.Option
+		want error
+	}{
+		{name: "all", n: 2},
+		{name: "eof", n: 3},
+		{name: "budget", n: 2, opts: []binser.Option{binser.WithMaxBytes(int64(len(raw)))}},
+		{name: "trailing", n: 1, want: binser.ErrTrailingData},
+		{name: "trailing-budget", n: 1, opts: []binser.Option{binser.WithMaxBytes(int64(len(raw) - 4))}, want: binser.ErrTrailingData},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := binser.NewDecoder(bytes.NewReader(raw), tc.opts...)
+			for i := 0; i < tc.n; i++ {
+				var v int32
+				err := dec.Decode(&v)
+				switch {
+				case i < 2 && err != nil:
+					t.Fatalf("could not decode value %d: %v", i, err)
+				case i >= 2 && err != io.EOF:
+					t.Fatalf("invalid error: got=%v, want=%v", err, io.EOF)
+				}
+			}
+
+			err := dec.Finish()
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("invalid error: got=%v, want=%v", err, tc.want)
+			}
+			if tc.want == nil {
+				return
+			}
+			var e *binser.DecodeError
+			if !errors.As(err, &e) {
+				t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+			}
+			if got, want := e.Offset, int64(len(raw)-4); got != want {
+				t.Fatalf("invalid offset: got=%d, want=%d", got, want)
+			}
+		})
+	}
+}
+
+func TestCollection(t *testing.T) {
+	t.Run("testdata/data64.bin", func(t *testing.T) {
+		f, err := os.Open("testdata/data64.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		dec := binser.NewDecoder(f)
+		var want []manimal
+		for _, tc := range typeTestCases {
+			if tc.name == "[]animal" {
+				want = tc.want.([]manimal)
+				break
+			}
+			ptr := reflect.New(reflect.TypeOf(tc.want))
+			if err := dec.Decode(ptr.Interface()); err != nil {
+				t.Fatalf("could not read %q: %v", tc.name, err)
+			}
+		}
+
+		c, err := dec.OpenCollection(new([]manimal))
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		if got, want := c.Len(), len(want); got != want {
+			t.Fatalf("invalid length: got=%d, want=%d", got, want)
+		}
+		var v manimal
+		for i := 0; c.More(); i++ {
+			if err := c.Decode(&v); err != nil {
+				t.Fatalf("could not decode element %d: %v", i, err)
+			}
+			if v != want[i] {
+				t.Fatalf("invalid element %d: got=%#v, want=%#v", i, v, want[i])
+			}
+		}
+	})
+
+	const n = 2500
+	orders := make([]order, n)
+	for i := range orders {
+		orders[i].ID = int32(i)
+	}
+	buf := new(bytes.Buffer)
+	enc := binser.NewEncoder(buf)
+	for _, v := range []interface{}{orders, int32(42)} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("could not encode: %v", err)
+		}
+	}
+	raw := buf.Bytes()
+
+	t.Run("stream", func(t *testing.T) {
+		dec := binser.NewDecoder(bytes.NewReader(raw), binser.WithStrict())
+		c, err := dec.OpenCollection(new([]order))
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		if got, want := c.Len(), n; got != want {
+			t.Fatalf("invalid length: got=%d, want=%d", got, want)
+		}
+		if got, want := c.ItemVersion(), uint32(0); got != want {
+			t.Fatalf("invalid item version: got=%d, want=%d", got, want)
+		}
+
+		var v order
+		for i := 0; c.More(); i++ {
+			if err := c.Decode(&v); err != nil {
+				t.Fatalf("could not decode element %d: %v", i, err)
+			}
+			if got, want := v.ID, int32(i); got != want {
+				t.Fatalf("invalid element %d: got=%d, want=%d", i, got, want)
+			}
+		}
+		if err := c.Decode(&v); err != io.EOF {
+			t.Fatalf("invalid error: got=%v, want=%v", err, io.EOF)
+		}
+
+		var tail int32
+		if err := dec.Decode(&tail); err != nil {
+			t.Fatalf("could not decode value after collection: %v", err)
+		}
+		if tail != 42 {
+			t.Fatalf("invalid value after collection: got=%d, want=42", tail)
+		}
+		if err := dec.Finish(); err != nil {
+			t.Fatalf("could not finish: %v", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		dec := binser.NewDecoder(bytes.NewReader(raw[:len(raw)-4-4*(n-1)+2]))
+		c, err := dec.OpenCollection(new([]order))
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		var v order
+		if err := c.Decode(&v); err != nil {
+			t.Fatalf("could not decode first element: %v", err)
+		}
+		err = c.Decode(&v)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, io.ErrUnexpectedEOF)
+		}
+		var e *binser.DecodeError
+		if !errors.As(err, &e) {
+			t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+		}
+		if got, want := e.Path, "[]binser_test.order[1].ID"; got != want {
+			t.Fatalf("invalid path: got=%q, want=%q", got, want)
+		}
+		if got := c.Decode(&v); got != err {
+			t.Fatalf("decoder not in failed state: got=%v, want=%v", got, err)
+		}
+		if c.More() {
+			t.Fatalf("elements left to decode after a failure")
+		}
+	})
+
+	t.Run("wrong-type", func(t *testing.T) {
+		dec := binser.NewDecoder(bytes.NewReader(raw))
+		c, err := dec.OpenCollection(new([]order))
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		err = c.Decode(new(int32))
+		if !errors.Is(err, binser.ErrTypeNotSupported) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrTypeNotSupported)
+		}
+		var v order
+		if err := c.Decode(&v); err != nil {
+			t.Fatalf("could not decode first element: %v", err)
+		}
+		if v.ID != 0 {
+			t.Fatalf("invalid first element: got=%d, want=0", v.ID)
+		}
+	})
+
+	t.Run("not-a-slice", func(t *testing.T) {
+		dec := binser.NewDecoder(bytes.NewReader(raw))
+		_, err := dec.OpenCollection(new(order))
+		if !errors.Is(err, binser.ErrTypeNotSupported) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrTypeNotSupported)
+		}
+	})
+}
+
+type herd struct {
+	Name    string
+	Animals []animal `boost:",skip"`
+	Count   int32
+}
+
+// seen records the values it decodes, in place.
+type seen map[int32]bool
+
+func (s *seen) UnmarshalBoost(r *binser.RBuffer) error {
+	if *s == nil {
+		*s = make(seen)
+	}
+	(*s)[r.ReadI32()] = true
+	return r.Err()
+}
+
+func TestDecoderSkip(t *testing.T) {
+	for _, fname := range []string{
+		"testdata/data64.bin",
+		"testdata/data32.bin",
+	} {
+		for parity := 0; parity < 2; parity++ {
+			t.Run(fmt.Sprintf("%s-%d", fname, parity), func(t *testing.T) {
+				raw, err := os.ReadFile(fname)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				dec := binser.NewDecoder(bytes.NewReader(raw), binser.WithStrict())
+				for i, tc := range typeTestCases {
+					rt := reflect.TypeOf(tc.want)
+					if i%2 == parity {
+						if err := dec.Skip(rt); err != nil {
+							t.Fatalf("could not skip %q: %v", tc.name, err)
+						}
+						continue
+					}
+					rv := reflect.New(rt)
+					if err := dec.Decode(rv.Interface()); err != nil {
+						t.Fatalf("could not read %q: %v", tc.name, err)
+					}
+					if got, want := rv.Elem().Interface(), tc.want; !reflect.DeepEqual(got, want) {
+						t.Fatalf("got=%#v (%T)\nwant=%#v (%T)", got, got, want, want)
+					}
+				}
+				if err := dec.Finish(); err != nil {
+					t.Fatalf("could not finish: %v", err)
+				}
+			})
+		}
+	}
+
+	animals := []animal{{"tiger", 4, 1}, {"monkey", 4, 1}}
+	buf := new(bytes.Buffer)
+	enc := binser.NewEncoder(buf)
+	for _, v := range []interface{}{
+		herd{Name: "zoo", Animals: animals, Count: 2},
+		animals,
+		map[string][]animal{"cats": animals[:1], "apes": animals[1:]},
+		int32(42),
+	} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("could not encode %T: %v", v, err)
+		}
+	}
+	raw := buf.Bytes()
+
+	t.Run("tag", func(t *testing.T) {
+		dec := binser.NewDecoder(bytes.NewReader(raw), binser.WithStrict())
+		h := herd{Animals: []animal{{"lion", 4, 1}}}
+		if err := dec.Decode(&h); err != nil {
+			t.Fatalf("could not decode herd: %v", err)
+		}
+		want := herd{Name: "zoo", Animals: []animal{{"lion", 4, 1}}, Count: 2}
+		if !reflect.DeepEqual(h, want) {
+			t.Fatalf("invalid herd:\ngot= %#v\nwant=%#v", h, want)
+		}
+
+		// the class information of animal is read from the skipped field.
+		var got []animal
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("could not decode animals: %v", err)
+		}
+		if !reflect.DeepEqual(got, animals) {
+			t.Fatalf("invalid animals:\ngot= %#v\nwant=%#v", got, animals)
+		}
+
+		if err := dec.Skip(reflect.TypeOf(map[string][]animal(nil))); err != nil {
+			t.Fatalf("could not skip map: %v", err)
+		}
+		var tail int32
+		if err := dec.Decode(&tail); err != nil {
+			t.Fatalf("could not decode value after map: %v", err)
+		}
+		if tail != 42 {
+			t.Fatalf("invalid value after map: got=%d, want=42", tail)
+		}
+		if err := dec.Finish(); err != nil {
+			t.Fatalf("could not finish: %v", err)
+		}
+	})
+
+	t.Run("special", func(t *testing.T) {
+		x, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+		buf := new(bytes.Buffer)
+		err := binser.NewEncoder(buf).Encode(struct {
+			X *big.Int
+			S int32
+			N int32
+		}{x, 7, 42})
+		if err != nil {
+			t.Fatalf("could not encode: %v", err)
+		}
+
+		var v struct {
+			X big.Int `boost:",skip"`
+			S seen    `boost:",skip"`
+			N int32
+		}
+		v.X.SetString("999999999999999999999999999999", 10)
+		v.S = seen{1: true}
+		if err := binser.NewDecoder(buf).Decode(&v); err != nil {
+			t.Fatalf("could not decode: %v", err)
+		}
+		if got, want := v.X.String(), "999999999999999999999999999999"; got != want {
+			t.Fatalf("invalid skipped big.Int: got=%s, want=%s", got, want)
+		}
+		if got, want := v.S, (seen{1: true}); !reflect.DeepEqual(got, want) {
+			t.Fatalf("invalid skipped Unmarshaler: got=%v, want=%v", got, want)
+		}
+		if v.N != 42 {
+			t.Fatalf("invalid value after skipped fields: got=%d, want=42", v.N)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		type inner struct {
+			B  boostio.Bitset
+			Bs []boostio.Bitset
+		}
+		b := boostio.NewBitset(5)
+		b.Set(1, true)
+		buf := new(bytes.Buffer)
+		err := binser.NewEncoder(buf).Encode(struct {
+			I inner
+			N int32
+		}{inner{*b, []boostio.Bitset{*b, *b}}, 42})
+		if err != nil {
+			t.Fatalf("could not encode: %v", err)
+		}
+		raw := buf.Bytes()
+
+		var v struct {
+			I inner `boost:",skip"`
+			N int32
+		}
+		v.I = inner{*boostio.NewBitset(5), []boostio.Bitset{*boostio.NewBitset(5), *boostio.NewBitset(5)}}
+		if err := binser.NewDecoder(bytes.NewReader(raw)).Decode(&v); err != nil {
+			t.Fatalf("could not decode: %v", err)
+		}
+		if v.I.B.Count() != 0 || v.I.Bs[0].Count() != 0 || v.I.Bs[1].Count() != 0 {
+			t.Fatalf("skipped bitsets modified: %v", v.I)
+		}
+		if v.N != 42 {
+			t.Fatalf("invalid value after skipped field: got=%d, want=42", v.N)
+		}
+
+		// the size of the bitsets is only known from the skipped field.
+		dec := binser.NewDecoder(bytes.NewReader(raw))
+		err = dec.Skip(reflect.TypeOf(v))
+		if !errors.Is(err, binser.ErrTypeNotSupported) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrTypeNotSupported)
+		}
+	})
+
+	t.Run("allocs", func(t *testing.T) {
+		const n = 200
+		buf := new(bytes.Buffer)
+		enc := binser.NewEncoder(buf)
+		for i := 0; i < n; i++ {
+			if err := enc.Encode(animals); err != nil {
+				t.Fatalf("could not encode: %v", err)
+			}
+		}
+		dec := binser.NewDecoder(bytes.NewReader(buf.Bytes()))
+		rt := reflect.TypeOf(animals)
+		if err := dec.Skip(rt); err != nil {
+			t.Fatalf("could not skip: %v", err)
+		}
+		allocs := testing.AllocsPerRun(n/2, func() {
+			if err := dec.Skip(rt); err != nil {
+				t.Fatalf("could not skip: %v", err)
+			}
+		})
+		if allocs != 0 {
+			t.Fatalf("invalid number of allocations: got=%v, want=0", allocs)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := binser.NewEncoder(buf).Encode(herd{Animals: animals}); err != nil {
+			t.Fatalf("could not encode: %v", err)
+		}
+		// truncate the name of the last animal.
+		n := buf.Len() - 4 - 1 - 2 - 1
+		dec := binser.NewDecoder(bytes.NewReader(buf.Bytes()[:n]))
+		err := dec.Skip(reflect.TypeOf(herd{}))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, io.ErrUnexpectedEOF)
+		}
+		var e *binser.DecodeError
+		if !errors.As(err, &e) {
+			t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+		}
+		if got, want := e.Path, "herd.Animals[1].Name"; got != want {
+			t.Fatalf("invalid path: got=%q, want=%q", got, want)
+		}
+		if got, want := e.Offset, int64(n); got != want {
+			t.Fatalf("invalid offset: got=%d, want=%d", got, want)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		dec := binser.NewDecoder(bytes.NewReader(raw))
+		var e *binser.InvalidUnmarshalError
+		if err := dec.Skip(nil); !errors.As(err, &e) {
+			t.Fatalf("invalid error: got=%v, want=%T", err, e)
+		}
+	})
+}
+
+// cancelReader reads from r, and calls cancel once n bytes have been read.
+type cancelReader struct {
+	r      io.Reader
+	n      int
+	cancel func()
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n -= n
+	if r.n <= 0 {
+		r.cancel()
+	}
+	return n, err
+}
+
+func TestDecoderContext(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := binser.NewEncoder(buf).Encode(make([]int32, 10000))
+	if err != nil {
+		t.Fatalf("could not encode: %v", err)
+	}
+	raw := buf.Bytes()
+
+	for _, tc := range []struct {
+		name string
+		n    int // number of bytes read before the context is cancelled.
+		path string
+	}{
+		{name: "before", n: 0, path: "[]int32"},
+		{name: "during", n: 20000, path: "[]int32[5120]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			dec := binser.NewDecoder(&cancelReader{r: bytes.NewReader(raw), n: tc.n, cancel: cancel})
+
+			var v []int32
+			err := dec.DecodeContext(ctx, &v)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("invalid error: got=%v, want=%v", err, context.Canceled)
+			}
+			var e *binser.DecodeError
+			if !errors.As(err, &e) {
+				t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+			}
+			if got, want := e.Path, tc.path; got != want {
+				t.Fatalf("invalid path: got=%q, want=%q", got, want)
+			}
+
+			if got := dec.Decode(&v); got != err {
+				t.Fatalf("decoder not in failed state: got=%v, want=%v", got, err)
+			}
+		})
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	// each target is a sequence of types decoded one after the other.
+	var targets [][]reflect.Type
+	for _, tc := range archiveTestCases {
+		var types []reflect.Type
+		for _, want := range tc.want {
+			types = append(types, reflect.TypeOf(want))
+		}
+		f.Add(uint(len(targets)), archive64(tc.raw))
+		targets = append(targets, types)
+	}
+	for _, tc := range typeTestCases {
+		buf := new(bytes.Buffer)
+		err := binser.NewEncoder(buf).Encode(tc.want)
+		if err != nil {
+			f.Fatalf("could not encode %s: %v", tc.name, err)
+		}
+		f.Add(uint(len(targets)), buf.Bytes())
+		targets = append(targets, []reflect.Type{reflect.TypeOf(tc.want)})
+	}
+
+	f.Fuzz(func(t *testing.T, i uint, raw []byte) {
+		dec := binser.NewDecoder(
+			bytes.NewReader(raw),
+			binser.WithMaxStringLen(1<<12),
+			binser.WithMaxCollectionLen(1<<12),
+			binser.WithMaxDepth(64),
+		)
+		for _, rt := range targets[i%uint(len(targets))] {
+			err := dec.Decode(reflect.New(rt).Interface())
+			if errors.Is(err, binser.ErrInternal) {
+				t.Fatalf("could not decode %v: %+v", rt, err)
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+}
+
+// panicker panics when decoded.
+type panicker struct{}
+
+func (*panicker) UnmarshalBoost(r *binser.RBuffer) error { panic("boom") }
+
+func TestDecoderPanicFree(t *testing.T) {
+	raw := archive64([]byte{1, 0, 0, 0})
+
+	for _, ptr := range []interface{}{nil, int32(1), (*int32)(nil)} {
+		err := binser.NewDecoder(bytes.NewReader(raw)).Decode(ptr)
+		var e *binser.InvalidUnmarshalError
+		if !errors.As(err, &e) {
+			t.Fatalf("invalid error for %#v: got=%v, want=%T", ptr, err, e)
+		}
+	}
+
+	// panics of user Unmarshalers are not recovered.
+	nested := new(bytes.Buffer)
+	if err := binser.NewEncoder(nested).Encode(&struct{ P int32 }{1}); err != nil {
+		t.Fatalf("could not encode: %v", err)
+	}
+	for _, tc := range []struct {
+		raw []byte
+		ptr interface{}
+	}{
+		{raw, &panicker{}},
+		{nested.Bytes(), &struct{ P panicker }{}},
+	} {
+		ptr := tc.ptr
+		dec := binser.NewDecoder(bytes.NewReader(tc.raw))
+		func() {
+			defer func() {
+				if e := recover(); e != "boom" {
+					t.Fatalf("invalid panic for %T: got=%v, want=%v", ptr, e, "boom")
+				}
+			}()
+			_ = dec.Decode(ptr)
+		}()
+		if err := dec.Decode(new(int32)); err == nil {
+			t.Fatalf("decoder not in failed state after a panic of %T", ptr)
+		}
 	}
 }
@@ -5,9 +5,19 @@
 package binser
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/go-boostio/boostio"
 )
 
 // An Encoder writes and encodes values to a Boost binary serialization stream.
@@ -16,18 +26,21 @@ type Encoder struct {
 	Header Header
 
 	hdr sync.Once
+
+	path  path // path of the value being encoded.
+	depth int  // nesting depth of the value being encoded.
 }
 
 // NewEncoder returns a new encoder that writes to w.
 //
 // The encoder writes a correct Boost binary header at the beginning of
 // the archive.
-func NewEncoder(w io.Writer) *Encoder {
-	return newEncoder(w, Arch64)
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	return newEncoder(w, Arch64, opts)
 }
 
-func newEncoder(w io.Writer, arch Arch) *Encoder {
-	ww := newWBuffer(w, arch)
+func newEncoder(w io.Writer, arch Arch, opts []Option) *Encoder {
+	ww := newWBuffer(w, arch, opts)
 	return &Encoder{w: ww}
 }
 
@@ -41,17 +54,158 @@ func (enc *Encoder) writeHeader() {
 }
 
 // Encode write the value v to its output.
+//
+// Maps are written as C++ std::map, with their keys sorted as by std::less,
+// so that their encoding is reproducible. Maps with distinct keys that
+// std::less can not order, such as several NaNs, are rejected with an error
+// matching ErrMapKeyOrder.
+//
+// Errors that occur while encoding the value are returned as an
+// *EncodeError.
 func (enc *Encoder) Encode(v interface{}) error {
 	enc.hdr.Do(enc.writeHeader)
+	return enc.run(v, func() error { return enc.encode(v) })
+}
+
+// EncodeContext is like Encode, but stops encoding with the error of ctx,
+// annotated as an *EncodeError, once ctx is done.
+// The encoder can not be used any further after such an error.
+//
+// The context is checked periodically while encoding collections.
+func (enc *Encoder) EncodeContext(ctx context.Context, v interface{}) error {
+	defer func(ctx context.Context) {
+		enc.w.ctx = ctx
+	}(enc.w.ctx)
+	enc.w.ctx = ctx
+	return enc.Encode(v)
+}
+
+// run runs f, which encodes the value v, one level deeper than the current
+// value.
+// The errors of f are annotated with the path of the value being encoded
+// and the output offset at which they occurred.
+func (enc *Encoder) run(v interface{}, f func() error) error {
+	if enc.w.err != nil {
+		return enc.w.err
+	}
+
+	n := len(enc.path)
+	if enc.depth == 0 {
+		n = 0
+		enc.path = append(enc.path[:0], pathElem{name: typeName(reflect.TypeOf(v))})
+		enc.w.poll(0)
+	}
+	defer func() {
+		enc.path = enc.path[:n]
+	}()
+
+	var err error
+	off := enc.w.off
+	enc.depth++
+	if enc.w.err == nil {
+		err = f()
+	}
+	enc.depth--
+	if enc.w.err == nil && err != nil && (enc.depth > 0 || enc.w.off != off) {
+		// the value, or its enclosing value, is only partly encoded: the
+		// output can not be written any further.
+		enc.w.err = err
+	}
 	if enc.w.err != nil {
+		enc.w.err = enc.fail(enc.w.err)
 		return enc.w.err
 	}
+	return enc.fail(err)
+}
+
+// fail returns err annotated with the path of the value being encoded and
+// the current output offset.
+func (enc *Encoder) fail(err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *EncodeError
+	if errors.As(err, &e) {
+		return err
+	}
+	return &EncodeError{Offset: enc.w.off, Path: enc.path.String(), Err: err}
+}
 
+func (enc *Encoder) encode(v interface{}) error {
 	if v, ok := v.(Marshaler); ok {
 		return v.MarshalBoost(enc.w)
 	}
 
+	switch v := v.(type) {
+	case *big.Int:
+		return enc.w.WriteBigInt(v)
+	case *big.Float:
+		return enc.w.WriteBigFloat(v)
+	case *big.Rat:
+		return enc.w.WriteBigRat(v)
+	}
+
 	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return ErrTypeNotSupported
+	}
+
+	if isEnum(rv.Type()) {
+		v := enumValue(rv)
+		if !boostio.ValidEnum(rv.Interface().(boostio.Enum), v) {
+			enc.w.err = fmt.Errorf("%w %v (%d) for %v", ErrInvalidEnum, rv.Interface(), v, rv.Type())
+			return enc.w.err
+		}
+		return enc.w.WriteEnum(v)
+	}
+
+	switch rv.Type() {
+	case bitsetType:
+		b := rv.Interface().(boostio.Bitset)
+		return enc.w.WriteBitset(&b)
+	case dynamicBitsetType:
+		b := rv.Interface().(boostio.DynamicBitset)
+		return enc.w.WriteDynamicBitset(&b)
+	case boolsType:
+		return enc.w.WriteVectorBool(rv.Interface().([]bool))
+	case wstringType:
+		return enc.w.WriteWString(rv.String())
+	case longDoubleType:
+		return enc.w.WriteLongDouble(rv.Interface().(boostio.LongDouble))
+	case bigIntType:
+		v := rv.Interface().(big.Int)
+		return enc.w.WriteBigInt(&v)
+	case bigFloatType:
+		v := rv.Interface().(big.Float)
+		return enc.w.WriteBigFloat(&v)
+	case bigRatType:
+		v := rv.Interface().(big.Rat)
+		return enc.w.WriteBigRat(&v)
+	case uuidType:
+		return enc.w.WriteUUID(rv.Interface().(boostio.UUID))
+	case vectorType:
+		return enc.w.WriteVector(rv.Interface().(boostio.Vector))
+	case matrixType:
+		m := rv.Interface().(boostio.Matrix)
+		return enc.w.WriteMatrix(&m)
+	case histogramType:
+		h := rv.Interface().(boostio.Histogram)
+		return enc.w.WriteHistogram(&h)
+	case ptreeType:
+		t := rv.Interface().(boostio.PTree)
+		return enc.w.WritePTree(&t)
+	case dateType:
+		return enc.w.WriteDate(rv.Interface().(boostio.Date))
+	case ptimeType:
+		return enc.w.WritePTime(rv.Interface().(boostio.PTime))
+	case timeDurationType:
+		return enc.w.WriteTimeDuration(rv.Interface().(boostio.TimeDuration))
+	case timeType:
+		return enc.w.WritePTime(boostio.PTime{Time: rv.Interface().(time.Time)})
+	case durationType:
+		return enc.w.WriteTimeDuration(boostio.TimeDuration{Duration: time.Duration(rv.Int())})
+	}
+
 	switch rv.Kind() {
 	case reflect.Bool:
 		enc.w.WriteBool(rv.Bool())
@@ -85,45 +239,474 @@ func (enc *Encoder) Encode(v interface{}) error {
 		rt := rv.Type()
 		enc.w.WriteTypeDescr(rt)
 		for i := 0; i < rt.NumField(); i++ {
-			enc.Encode(rv.Field(i).Interface())
+			enc.path.push(rt.Field(i).Name)
+			opts, err := parseTag(rt.Field(i))
+			if err != nil {
+				return err
+			}
+			switch {
+			case opts.multiIndex != nil:
+				err = enc.encodeMultiIndex(rv.Field(i), opts.multiIndex)
+				if err != nil {
+					return err
+				}
+			case opts.carray:
+				err = enc.encodeCArray(rv.Field(i))
+				if err != nil {
+					return err
+				}
+			case opts.array, opts.binary:
+				n, err := lenOf(rv, i, rv.Field(i), opts)
+				if err != nil {
+					return err
+				}
+				if n != rv.Field(i).Len() {
+					return ErrInvalidArrayLen
+				}
+				switch {
+				case opts.binary:
+					p := make([]byte, n)
+					reflect.Copy(reflect.ValueOf(p), rv.Field(i))
+					err = enc.w.WriteBinaryObject(p)
+				default:
+					err = enc.encodeArray(rv.Field(i))
+				}
+				if err != nil {
+					return err
+				}
+			default:
+				if err := enc.Encode(rv.Field(i).Interface()); err != nil {
+					return err
+				}
+			}
+			enc.path.pop()
 		}
 	case reflect.Slice:
 		rt := rv.Type()
 		enc.w.WriteTypeDescr(rt)
 		n := rv.Len()
 		enc.w.writeLen(n)
-		if et := rt.Elem(); !isCxxBoostBuiltin(et.Kind()) {
-			enc.w.WriteU32(0) // FIXME(sbinet): what is this?
+		if et := rt.Elem(); !isCxxBoostBuiltin(et.Kind()) || isEnum(et) {
+			enc.w.WriteU32(classVersion(et)) // item_version
 		}
+		enc.path.push("")
 		for i := 0; i < int(n); i++ {
+			enc.w.poll(i)
 			e := rv.Index(i)
-			enc.Encode(e.Interface()) // FIXME(sbinet): do not go through Decode each time
+			enc.path.index(i)
+			if err := enc.Encode(e.Interface()); err != nil { // FIXME(sbinet): do not go through Decode each time
+				return err
+			}
 		}
+		enc.path.pop()
 	case reflect.Array:
 		rt := rv.Type()
 		enc.w.WriteTypeDescr(rt)
 		n := int(rv.Len())
 		enc.w.writeLen(n)
+		enc.path.push("")
 		for i := 0; i < n; i++ {
+			enc.w.poll(i)
 			e := rv.Index(i)
-			enc.Encode(e.Interface()) // FIXME(sbinet): do not go through Decode each time
+			enc.path.index(i)
+			if err := enc.Encode(e.Interface()); err != nil { // FIXME(sbinet): do not go through Decode each time
+				return err
+			}
 		}
+		enc.path.pop()
 	case reflect.Map:
 		rt := rv.Type()
+		keys := rv.MapKeys()
+		if err := sortKeys(keys); err != nil {
+			return err
+		}
 		enc.w.WriteTypeDescr(rt)
 		n := int(rv.Len())
 		enc.w.writeLen(n)
-		enc.w.WriteU64(0) // FIXME(sbinet): what is this ?
-		enc.w.WriteU8(0)  // FIXME(sbinet): ditto ?
-		keys := rv.MapKeys()
-		for _, k := range keys {
+		enc.w.WriteU32(0) // item_version
+		pt := pairOf(rt)
+		enc.path.push("")
+		for i, k := range keys {
+			enc.w.poll(i)
 			v := rv.MapIndex(k)
-			enc.Encode(k.Interface()) // FIXME(sbinet): do not go through Decode each time
-			enc.Encode(v.Interface()) // FIXME(sbinet): do not go through Decode each time
+			enc.w.WriteTypeDescr(pt)
+			enc.path.index(i)
+			enc.path.push("first")
+			if err := enc.Encode(k.Interface()); err != nil { // FIXME(sbinet): do not go through Decode each time
+				return err
+			}
+			enc.path.pop()
+			enc.path.push("second")
+			if err := enc.Encode(v.Interface()); err != nil { // FIXME(sbinet): do not go through Decode each time
+				return err
+			}
+			enc.path.pop()
+		}
+		enc.path.pop()
+
+	default:
+		return ErrTypeNotSupported
+	}
+	return enc.w.err
+}
+
+// A CollectionEncoder writes the elements of a collection one at a time, so
+// that collections need not be held in memory.
+//
+// The encoder of a collection must not be used for other values until the
+// collection is closed.
+type CollectionEncoder struct {
+	enc *Encoder
+	rt  reflect.Type // type of the slice holding the collection.
+	n   int          // number of elements, or -1 if it is back-patched.
+	i   int          // number of elements written.
+
+	ws  io.WriteSeeker // output of the encoder, if the count is back-patched.
+	pos int64          // offset of the count in ws.
+}
+
+// OpenCollection writes the class information, the number of elements and
+// the item version of a collection such as a std::vector, described by the
+// type of the slice v, and returns a CollectionEncoder to write its n
+// elements one at a time.
+//
+// If n is negative, the number of elements is only written when the
+// collection is closed: the output of the encoder must then be an
+// io.WriteSeeker.
+func (enc *Encoder) OpenCollection(v interface{}, n int) (*CollectionEncoder, error) {
+	enc.hdr.Do(enc.writeHeader)
+	c := &CollectionEncoder{enc: enc, rt: reflect.TypeOf(v), n: n}
+	err := enc.run(v, func() error {
+		if c.rt == nil || c.rt.Kind() != reflect.Slice {
+			return ErrTypeNotSupported
+		}
+		if n < 0 {
+			ws, ok := enc.w.w.(io.WriteSeeker)
+			if !ok {
+				return fmt.Errorf("%w: unknown number of elements with an output that is not an io.WriteSeeker", ErrCollectionLen)
+			}
+			c.ws = ws
+		}
+		enc.w.WriteTypeDescr(c.rt)
+		switch {
+		case c.ws != nil && enc.w.err == nil:
+			// the count is written once all the elements are.
+			c.pos, enc.w.err = c.ws.Seek(0, io.SeekCurrent)
+			enc.w.writeLen(0)
+		default:
+			enc.w.writeLen(n)
+		}
+		if et := c.rt.Elem(); !isCxxBoostBuiltin(et.Kind()) || isEnum(et) {
+			enc.w.WriteU32(classVersion(et)) // item_version
+		}
+		return enc.w.err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Encode writes v as the next element of the collection.
+// v must be a value of the element type, or a pointer to such a value:
+// other values are rejected with an error matching ErrTypeNotSupported,
+// before anything is written.
+//
+// Errors that occur while encoding the element are returned as an
+// *EncodeError, and the encoder can not be used any further.
+func (c *CollectionEncoder) Encode(v interface{}) error {
+	enc := c.enc
+	if enc.w.err != nil {
+		return enc.w.err
+	}
+	if c.n >= 0 && c.i >= c.n {
+		return fmt.Errorf("%w: more than %d elements", ErrCollectionLen, c.n)
+	}
+	if et, rt := c.rt.Elem(), reflect.TypeOf(v); rt != et && (rt == nil || rt.Kind() != reflect.Ptr || rt.Elem() != et) {
+		return fmt.Errorf("%w: %v encoded as a %v element", ErrTypeNotSupported, rt, et)
+	}
+
+	// encode the element as a value nested in the collection.
+	enc.path = append(enc.path[:0], pathElem{name: typeName(c.rt)})
+	enc.path.push("")
+	enc.path.index(c.i)
+	enc.depth = 1
+	err := enc.run(v, func() error {
+		if enc.w.poll(c.i); enc.w.err != nil {
+			return enc.w.err
+		}
+		return enc.encode(v)
+	})
+	enc.depth = 0
+	enc.path = enc.path[:0]
+	c.i++
+	return err
+}
+
+// Close ends the collection, writing its number of elements if it was not
+// known when the collection was opened.
+//
+// Close returns an error matching ErrCollectionLen if the number of
+// elements given to OpenCollection was not written, after which the encoder
+// can not be used any further.
+func (c *CollectionEncoder) Close() error {
+	enc := c.enc
+	if enc.w.err != nil {
+		return enc.w.err
+	}
+	var err error
+	switch {
+	case c.n < 0:
+		c.n = c.i
+		err = enc.w.patchLen(c.ws, c.pos, c.n)
+	case c.i != c.n:
+		err = fmt.Errorf("%w: %d elements written out of %d", ErrCollectionLen, c.i, c.n)
+	}
+	if err != nil {
+		// the collection is not correctly written: the output can not be
+		// written any further.
+		enc.path = append(enc.path[:0], pathElem{name: typeName(c.rt)})
+		enc.w.err = enc.fail(err)
+		enc.path = enc.path[:0]
+	}
+	return enc.w.err
+}
+
+// EncodeMultiIndex writes the elements of the slice v as a
+// boost::multi_index_container, in the order of its first index.
+//
+// The types of the indices of the container must be given, in order.
+// The other indices of the container are written with the order of their
+// elements left to the C++ side.
+func (enc *Encoder) EncodeMultiIndex(v interface{}, indices ...boostio.IndexKind) error {
+	enc.hdr.Do(enc.writeHeader)
+	return enc.run(v, func() error {
+		return enc.encodeMultiIndex(reflect.Indirect(reflect.ValueOf(v)), indices)
+	})
+}
+
+func (enc *Encoder) encodeMultiIndex(rv reflect.Value, indices []boostio.IndexKind) error {
+	if rv.Kind() != reflect.Slice || len(indices) == 0 {
+		return ErrTypeNotSupported
+	}
+	var (
+		w       = enc.w
+		rt      = rv.Type()
+		node    = nodeOf(rt)
+		tracked = tracksNodes(indices)
+	)
+	w.writeTypeDescr(multiIndexOf(rt), TypeDescr{Version: multiIndexVersion})
+	w.writeLen(rv.Len())
+	w.WriteTypeDescr(valueVersionOf(rt.Elem()))
+	enc.path.push("")
+	for i := 0; i < rv.Len(); i++ {
+		enc.w.poll(i)
+		enc.path.index(i)
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+		w.writeNodePosition(node, tracked)
+	}
+	enc.path.pop()
+	end := w.writeNodePosition(node, tracked) // header node
+
+	for _, k := range indices {
+		if k.Hashed() {
+			w.WriteTypeDescr(bucketsOf(rt))
+		}
+		if !k.Positional() {
+			continue
 		}
+		// no displaced node: only the pointer to the header node.
+		w.WriteI16(w.cids[node])
+		w.WriteU32(end)
+	}
+	return w.err
+}
 
+// EncodeCArray writes the array v as a C array.
+//
+// Nested Go arrays are written as the dimensions of a multi-dimensional C
+// array (e.g. [3][4]float64 for a double m[3][4]).
+func (enc *Encoder) EncodeCArray(v interface{}) error {
+	enc.hdr.Do(enc.writeHeader)
+	return enc.run(v, func() error { return enc.encodeCArray(reflect.Indirect(reflect.ValueOf(v))) })
+}
+
+func (enc *Encoder) encodeCArray(rv reflect.Value) error {
+	if rv.Kind() != reflect.Array {
+		return ErrTypeNotSupported
+	}
+	n := rv.Len()
+	enc.w.writeLen(n)
+	enc.path.push("")
+	for i := 0; i < n; i++ {
+		enc.w.poll(i)
+		e := rv.Index(i)
+		enc.path.index(i)
+		switch {
+		case isCArray(e.Type()):
+			err := enc.encodeCArray(e)
+			if err != nil {
+				return err
+			}
+		default:
+			if err := enc.Encode(e.Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	enc.path.pop()
+	return enc.w.err
+}
+
+// EncodeArray writes the elements of the slice or array v as with
+// make_array, ie. with no count.
+func (enc *Encoder) EncodeArray(v interface{}) error {
+	enc.hdr.Do(enc.writeHeader)
+	return enc.run(v, func() error { return enc.encodeArray(reflect.Indirect(reflect.ValueOf(v))) })
+}
+
+func (enc *Encoder) encodeArray(rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
 	default:
 		return ErrTypeNotSupported
 	}
+	enc.path.push("")
+	for i := 0; i < rv.Len() && enc.w.err == nil; i++ {
+		enc.w.poll(i)
+		enc.path.index(i)
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	enc.path.pop()
 	return enc.w.err
 }
+
+// sortKeys sorts the keys of a map in the order a C++ std::map orders them
+// with std::less: numbers in numeric order, strings in lexicographic byte
+// order, and arrays and structs member-wise.
+//
+// Distinct keys that compare equal, such as NaNs or times of the same
+// instant in different locations, can not be held by a std::map, and would
+// be written in a random order: they are reported as an error matching
+// ErrMapKeyOrder.
+func sortKeys(keys []reflect.Value) error {
+	sort.Slice(keys, func(i, j int) bool { return compareKeys(keys[i], keys[j]) < 0 })
+	for i := 1; i < len(keys); i++ {
+		if compareKeys(keys[i-1], keys[i]) == 0 {
+			return fmt.Errorf("%w: keys %v and %v are equivalent", ErrMapKeyOrder, keys[i-1], keys[i])
+		}
+	}
+	return nil
+}
+
+// compareKeys returns -1, 0 or +1 depending on whether the map key a is
+// less than, equal to or greater than the map key b of the same type.
+func compareKeys(a, b reflect.Value) int {
+	if a.CanInterface() {
+		switch va := a.Interface().(type) {
+		case time.Time:
+			vb := b.Interface().(time.Time)
+			switch {
+			case va.Before(vb):
+				return -1
+			case va.After(vb):
+				return +1
+			}
+			return 0
+		case *big.Int:
+			if va != nil && !b.IsNil() {
+				return va.Cmp(b.Interface().(*big.Int))
+			}
+		case *big.Float:
+			if va != nil && !b.IsNil() {
+				return va.Cmp(b.Interface().(*big.Float))
+			}
+		case *big.Rat:
+			if va != nil && !b.IsNil() {
+				return va.Cmp(b.Interface().(*big.Rat))
+			}
+		}
+	}
+
+	switch a.Kind() {
+	case reflect.Bool:
+		return compareOrdered(boolToInt(a.Bool()), boolToInt(b.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareFloats(a.Float(), b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		ca, cb := a.Complex(), b.Complex()
+		if c := compareFloats(real(ca), real(cb)); c != 0 {
+			return c
+		}
+		return compareFloats(imag(ca), imag(cb))
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if c := compareKeys(a.Index(i), b.Index(i)); c != 0 {
+				return c
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if c := compareKeys(a.Field(i), b.Field(i)); c != 0 {
+				return c
+			}
+		}
+	case reflect.Ptr, reflect.Interface:
+		switch {
+		case a.IsNil() || b.IsNil():
+			return compareOrdered(boolToInt(!a.IsNil()), boolToInt(!b.IsNil()))
+		case a.Elem().Type() == b.Elem().Type():
+			return compareKeys(a.Elem(), b.Elem())
+		}
+		// interfaces holding values of different types are ordered by type.
+		ta, tb := a.Elem().Type(), b.Elem().Type()
+		if c := strings.Compare(ta.PkgPath(), tb.PkgPath()); c != 0 {
+			return c
+		}
+		return strings.Compare(ta.String(), tb.String())
+	}
+	return 0
+}
+
+// compareOrdered returns -1, 0 or +1 depending on whether a is less than,
+// equal to or greater than b.
+func compareOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return +1
+	}
+	return 0
+}
+
+// compareFloats is like compareOrdered, but orders NaNs before all the other
+// values, so that floats are totally ordered.
+func compareFloats(a, b float64) int {
+	switch na, nb := math.IsNaN(a), math.IsNaN(b); {
+	case na && nb:
+		return 0
+	case na:
+		return -1
+	case nb:
+		return +1
+	}
+	return compareOrdered(a, b)
+}
+
+func boolToInt(v bool) int64 {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -6,16 +6,22 @@ package binser_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/big"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/go-boostio/boostio"
 	"github.com/go-boostio/boostio/binser"
 )
 
@@ -46,6 +52,7 @@ var typeTestCases = []struct {
 	{"struct-marshal", manimal{"pet", 4, 1}},
 	{"[]string", []string{"s1", "s2", "s3"}},
 	{"[]animal", []manimal{{"tiger", 4, 1}, {"monkey", 4, 1}}},
+	{"[]bool", []bool{true, false, true}},
 }
 
 func TestEncoder(t *testing.T) {
@@ -98,6 +105,54 @@ func TestEncoderError(t *testing.T) {
 	}
 }
 
+func TestEncoderArchive(t *testing.T) {
+	for _, tc := range archiveTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			enc := binser.NewEncoder(buf)
+			for _, v := range tc.want {
+				err := enc.Encode(v)
+				if err != nil {
+					t.Fatalf("could not encode %T: %v", v, err)
+				}
+			}
+
+			if got, want := buf.Bytes(), archive64(tc.raw); !bytes.Equal(got, want) {
+				t.Fatalf("invalid archive:\ngot:\n%s\nwant:\n%s", hex.Dump(got), hex.Dump(want))
+			}
+		})
+	}
+}
+
+func TestEncoderArchiveRoundTrip(t *testing.T) {
+	for _, arch := range []binser.Arch{binser.Arch32, binser.Arch64} {
+		for _, tc := range archiveTestCases {
+			t.Run(fmt.Sprintf("%s-%d", tc.name, arch), func(t *testing.T) {
+				buf := new(bytes.Buffer)
+				enc := arch.NewEncoder(buf)
+				for _, v := range tc.want {
+					err := enc.Encode(v)
+					if err != nil {
+						t.Fatalf("could not encode %T: %v", v, err)
+					}
+				}
+
+				dec := binser.NewDecoder(buf)
+				for _, want := range tc.want {
+					rv := newValue(want)
+					err := dec.Decode(rv.Addr().Interface())
+					if err != nil {
+						t.Fatalf("could not decode %T: %v", want, err)
+					}
+					if got := rv.Interface(); !equal(got, want) {
+						t.Fatalf("round trip failed:\ngot= %#v\nwant=%#v", got, want)
+					}
+				}
+			})
+		}
+	}
+}
+
 func TestWBufferWriter(t *testing.T) {
 	want := []byte("hello")
 	buf := new(bytes.Buffer)
@@ -121,13 +176,14 @@ func TestEncoderInvalidType(t *testing.T) {
 		t.Fatalf("expected an error")
 	}
 
-	if got, want := err, binser.ErrTypeNotSupported; !reflect.DeepEqual(got, want) {
+	if got, want := err, binser.ErrTypeNotSupported; !errors.Is(got, want) {
 		t.Fatalf("got=%#v, want=%#v", got, want)
 	}
 }
 
 func TestEncoderCompatWithBoost64(t *testing.T) {
-	f, err := os.Create("testdata/check64.bin")
+	tmp := t.TempDir()
+	f, err := os.Create(filepath.Join(tmp, "check64.bin"))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -146,12 +202,6 @@ func TestEncoderCompatWithBoost64(t *testing.T) {
 		t.Fatalf("error closing output stream: %v", err)
 	}
 
-	tmp, err := os.MkdirTemp("", "boostio-binser-")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer os.RemoveAll(tmp)
-
 	fname := filepath.Join(tmp, "read.cxx")
 	err = os.WriteFile(fname, []byte(boostReadSrc), 0644)
 	if err != nil {
@@ -166,8 +216,6 @@ func TestEncoderCompatWithBoost64(t *testing.T) {
 	err = cmd.Run()
 	if err != nil {
 		t.Skipf("could not compile C++ Boost: %s", dbg.Bytes())
-		os.Remove(f.Name())
-		return
 	}
 
 	archive, err := os.ReadFile(f.Name())
@@ -207,16 +255,16 @@ animal: {name: pet, legs: 4, tails: 1}
 animal: {name: pet, legs: 4, tails: 1}
 []string: {s1, s2, s3, }
 []animal: {{name: tiger, legs: 4, tails: 1}, {name: monkey, legs: 4, tails: 1}, }
+[]bool: {1, 0, 1, }
 `
 	if got, want := out.Bytes(), []byte(want); !bytes.Equal(got, want) {
 		t.Fatalf("output differs:\ngot:\n%s\nwant:%s\n", got, want)
 	}
-
-	os.Remove(f.Name())
 }
 
 func TestEncoderCompatWithBoost32(t *testing.T) {
-	f, err := os.Create("testdata/check32.bin")
+	tmp := t.TempDir()
+	f, err := os.Create(filepath.Join(tmp, "check32.bin"))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -235,12 +283,6 @@ func TestEncoderCompatWithBoost32(t *testing.T) {
 		t.Fatalf("error closing output stream: %v", err)
 	}
 
-	tmp, err := os.MkdirTemp("", "boostio-binser-")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer os.RemoveAll(tmp)
-
 	fname := filepath.Join(tmp, "read.cxx")
 	err = os.WriteFile(fname, []byte(boostReadSrc), 0644)
 	if err != nil {
@@ -255,8 +297,6 @@ func TestEncoderCompatWithBoost32(t *testing.T) {
 	err = cmd.Run()
 	if err != nil {
 		t.Skipf("could not compile C++ Boost: %s", dbg.Bytes())
-		os.Remove(f.Name())
-		return
 	}
 
 	archive, err := os.ReadFile(f.Name())
@@ -296,12 +336,11 @@ animal: {name: pet, legs: 4, tails: 1}
 animal: {name: pet, legs: 4, tails: 1}
 []string: {s1, s2, s3, }
 []animal: {{name: tiger, legs: 4, tails: 1}, {name: monkey, legs: 4, tails: 1}, }
+[]bool: {1, 0, 1, }
 `
 	if got, want := out.Bytes(), []byte(want); !bytes.Equal(got, want) {
 		t.Fatalf("output differs:\ngot:\n%s\nwant:%s\n", got, want)
 	}
-
-	os.Remove(f.Name())
 }
 
 const boostReadSrc = `
@@ -501,5 +540,458 @@ int main()
 	  for (auto v: vs) { std::cout << "{name: " << v.name() << ", legs: " << v.legs() << ", tails: " << v.tails() << "}, "; }
 	  std::cout << "}\n";
   }
+
+  {
+	  std::vector<bool> vs;
+	  ia >> vs;
+	  std::cout << "[]bool: {";
+	  for (auto v: vs) { std::cout << v << ", "; }
+	  std::cout << "}\n";
+  }
 }
 `
+
+func TestEncoderMapOrder(t *testing.T) {
+	// the archives written by C++ hold the maps of typeTestCases, up to the
+	// first struct, in std::map order.
+	for _, tc := range []struct {
+		arch  binser.Arch
+		fname string
+	}{
+		{binser.Arch64, "testdata/data64.bin"},
+		{binser.Arch32, "testdata/data32.bin"},
+	} {
+		t.Run(tc.fname, func(t *testing.T) {
+			want, err := os.ReadFile(tc.fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf := new(bytes.Buffer)
+			enc := tc.arch.NewEncoder(buf)
+			for _, tc := range typeTestCases {
+				if tc.name == "struct" {
+					break
+				}
+				err := enc.Encode(tc.want)
+				if err != nil {
+					t.Fatalf("could not encode %q: %v", tc.name, err)
+				}
+			}
+			if got, want := buf.Bytes(), want[:buf.Len()]; !bytes.Equal(got, want) {
+				t.Fatalf("invalid archive:\ngot:\n%s\nwant:\n%s", hex.Dump(got), hex.Dump(want))
+			}
+		})
+	}
+
+	type key struct {
+		A int32
+		B string
+	}
+	// a std::map is written as a sequence of std::pair, as a std::vector
+	// of std::pair.
+	for _, tc := range []struct {
+		name string
+		m    interface{}
+		want interface{}
+	}{
+		{
+			name: "int32",
+			m:    map[int32]string{3: "c", -1: "a", 0: "b", 1 << 20: "d"},
+			want: []boostio.Pair[int32, string]{{First: -1, Second: "a"}, {First: 0, Second: "b"}, {First: 3, Second: "c"}, {First: 1 << 20, Second: "d"}},
+		},
+		{
+			name: "uint64",
+			m:    map[uint64]bool{1 << 63: true, 0: false, 2: true},
+			want: []boostio.Pair[uint64, bool]{{First: 0, Second: false}, {First: 2, Second: true}, {First: 1 << 63, Second: true}},
+		},
+		{
+			name: "float64",
+			m:    map[float64]int8{0.5: 2, -2: 1, 3: 3},
+			want: []boostio.Pair[float64, int8]{{First: -2, Second: 1}, {First: 0.5, Second: 2}, {First: 3, Second: 3}},
+		},
+		{
+			name: "string",
+			m:    map[string]int32{"b": 3, "ab": 2, "": 0, "a": 1, "\xff": 4},
+			want: []boostio.Pair[string, int32]{{First: "", Second: 0}, {First: "a", Second: 1}, {First: "ab", Second: 2}, {First: "b", Second: 3}, {First: "\xff", Second: 4}},
+		},
+		{
+			name: "array",
+			m:    map[[2]uint8]int32{{2, 0}: 3, {1, 9}: 2, {1, 0}: 1},
+			want: []boostio.Pair[[2]uint8, int32]{{First: [2]uint8{1, 0}, Second: 1}, {First: [2]uint8{1, 9}, Second: 2}, {First: [2]uint8{2, 0}, Second: 3}},
+		},
+		{
+			name: "struct",
+			m:    map[key]int32{{2, "a"}: 3, {1, "b"}: 2, {1, "a"}: 1},
+			want: []boostio.Pair[key, int32]{{First: key{1, "a"}, Second: 1}, {First: key{1, "b"}, Second: 2}, {First: key{2, "a"}, Second: 3}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			err := binser.NewEncoder(buf).Encode(tc.m)
+			if err != nil {
+				t.Fatalf("could not encode: %v", err)
+			}
+			raw := buf.Bytes()
+
+			for i := 0; i < 10; i++ {
+				buf := new(bytes.Buffer)
+				err := binser.NewEncoder(buf).Encode(tc.m)
+				if err != nil {
+					t.Fatalf("could not encode: %v", err)
+				}
+				if !bytes.Equal(buf.Bytes(), raw) {
+					t.Fatalf("encoding is not reproducible")
+				}
+			}
+
+			got := reflect.New(reflect.TypeOf(tc.want))
+			err = binser.NewDecoder(bytes.NewReader(raw)).Decode(got.Interface())
+			if err != nil {
+				t.Fatalf("could not decode: %v", err)
+			}
+			if got := got.Elem().Interface(); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("invalid order:\ngot= %v\nwant=%v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("interface", func(t *testing.T) {
+		// values of different types are ordered by type name.
+		m := map[interface{}]int8{uint8(1): 3, "a": 2, int32(2): 1}
+		buf := new(bytes.Buffer)
+		enc := binser.NewEncoder(buf)
+		if err := enc.Encode(int32(0)); err != nil {
+			t.Fatalf("could not encode: %v", err)
+		}
+		n := buf.Len()
+		for _, v := range []interface{}{int32(2), int8(1), "a", int8(2), uint8(1), int8(3)} {
+			if err := enc.Encode(v); err != nil {
+				t.Fatalf("could not encode: %v", err)
+			}
+		}
+		want := buf.Bytes()[n:] // pairs, after the class info of the first one.
+
+		for i := 0; i < 10; i++ {
+			buf := new(bytes.Buffer)
+			if err := binser.NewEncoder(buf).Encode(m); err != nil {
+				t.Fatalf("could not encode: %v", err)
+			}
+			if got := buf.Bytes(); !bytes.HasSuffix(got, want) {
+				t.Fatalf("invalid order:\ngot:\n%s\nwant suffix:\n%s", hex.Dump(got), hex.Dump(want))
+			}
+		}
+	})
+
+	for _, tc := range []struct {
+		name string
+		m    interface{}
+	}{
+		{
+			name: "nan",
+			m:    map[float64]int8{math.NaN(): 1, math.NaN(): 2, 1: 3},
+		},
+		{
+			name: "time",
+			m: map[time.Time]int8{
+				time.Unix(0, 0).UTC():                         1,
+				time.Unix(0, 0).In(time.FixedZone("X", 3600)): 2,
+			},
+		},
+		{
+			name: "pointer",
+			m:    map[*big.Int]int8{big.NewInt(1): 1, big.NewInt(1): 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := binser.NewEncoder(new(bytes.Buffer)).Encode(tc.m)
+			if !errors.Is(err, binser.ErrMapKeyOrder) {
+				t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrMapKeyOrder)
+			}
+		})
+	}
+}
+
+func TestCollectionEncoder(t *testing.T) {
+	orders := make([]order, 2500)
+	for i := range orders {
+		orders[i].ID = int32(i)
+	}
+
+	for _, arch := range []binser.Arch{binser.Arch32, binser.Arch64} {
+		want := new(bytes.Buffer)
+		enc := arch.NewEncoder(want)
+		for _, v := range []interface{}{orders, []int32{1, 2}, int32(42)} {
+			if err := enc.Encode(v); err != nil {
+				t.Fatalf("could not encode: %v", err)
+			}
+		}
+
+		for _, tc := range []struct {
+			name string
+			n    int
+		}{
+			{"up-front", len(orders)},
+			{"back-patched", -1},
+		} {
+			t.Run(fmt.Sprintf("%s-%d", tc.name, arch), func(t *testing.T) {
+				f, err := os.CreateTemp(t.TempDir(), "collection-*.bin")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+
+				enc := arch.NewEncoder(f)
+				c, err := enc.OpenCollection([]order(nil), tc.n)
+				if err != nil {
+					t.Fatalf("could not open collection: %v", err)
+				}
+				for _, v := range orders {
+					if err := c.Encode(v); err != nil {
+						t.Fatalf("could not encode element: %v", err)
+					}
+				}
+				if err := c.Close(); err != nil {
+					t.Fatalf("could not close collection: %v", err)
+				}
+
+				n := 2
+				if tc.n < 0 {
+					n = -1
+				}
+				c, err = enc.OpenCollection([]int32(nil), n)
+				if err != nil {
+					t.Fatalf("could not open collection: %v", err)
+				}
+				for _, v := range []int32{1, 2} {
+					if err := c.Encode(v); err != nil {
+						t.Fatalf("could not encode element: %v", err)
+					}
+				}
+				if err := c.Close(); err != nil {
+					t.Fatalf("could not close collection: %v", err)
+				}
+
+				if err := enc.Encode(int32(42)); err != nil {
+					t.Fatalf("could not encode value after collections: %v", err)
+				}
+
+				got, err := os.ReadFile(f.Name())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want.Bytes()) {
+					t.Fatalf("invalid archive:\ngot:\n%s\nwant:\n%s", hex.Dump(got[:80]), hex.Dump(want.Bytes()[:80]))
+				}
+			})
+		}
+	}
+
+	t.Run("not-seekable", func(t *testing.T) {
+		_, err := binser.NewEncoder(new(bytes.Buffer)).OpenCollection([]order(nil), -1)
+		if !errors.Is(err, binser.ErrCollectionLen) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrCollectionLen)
+		}
+	})
+
+	t.Run("too-many", func(t *testing.T) {
+		c, err := binser.NewEncoder(new(bytes.Buffer)).OpenCollection([]order(nil), 1)
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		if err := c.Encode(order{1}); err != nil {
+			t.Fatalf("could not encode element: %v", err)
+		}
+		if err := c.Encode(order{2}); !errors.Is(err, binser.ErrCollectionLen) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrCollectionLen)
+		}
+	})
+
+	t.Run("wrong-type", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c, err := binser.NewEncoder(buf).OpenCollection([]order(nil), 2)
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		n := buf.Len()
+		for _, v := range []interface{}{int32(1), pixel{red, 1}, nil} {
+			err := c.Encode(v)
+			if !errors.Is(err, binser.ErrTypeNotSupported) {
+				t.Fatalf("invalid error for %T: got=%v, want=%v", v, err, binser.ErrTypeNotSupported)
+			}
+		}
+		if got := buf.Len(); got != n {
+			t.Fatalf("invalid element written: got=%d bytes, want=%d", got, n)
+		}
+		for _, v := range []interface{}{order{1}, &order{2}} {
+			if err := c.Encode(v); err != nil {
+				t.Fatalf("could not encode %T: %v", v, err)
+			}
+		}
+		if err := c.Close(); err != nil {
+			t.Fatalf("could not close collection: %v", err)
+		}
+	})
+
+	t.Run("too-few", func(t *testing.T) {
+		enc := binser.NewEncoder(new(bytes.Buffer))
+		c, err := enc.OpenCollection([]order(nil), 2)
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		if err := c.Encode(order{1}); err != nil {
+			t.Fatalf("could not encode element: %v", err)
+		}
+		err = c.Close()
+		if !errors.Is(err, binser.ErrCollectionLen) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrCollectionLen)
+		}
+		var e *binser.EncodeError
+		if !errors.As(err, &e) {
+			t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+		}
+		if got, want := e.Path, "[]binser_test.order"; got != want {
+			t.Fatalf("invalid path: got=%q, want=%q", got, want)
+		}
+		if got := enc.Encode(int32(42)); got != err {
+			t.Fatalf("encoder not in failed state: got=%v, want=%v", got, err)
+		}
+	})
+
+	t.Run("invalid-element", func(t *testing.T) {
+		c, err := binser.NewEncoder(new(bytes.Buffer)).OpenCollection([]pixel(nil), 2)
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		if err := c.Encode(pixel{red, 1}); err != nil {
+			t.Fatalf("could not encode element: %v", err)
+		}
+		err = c.Encode(pixel{color(42), 2})
+		var e *binser.EncodeError
+		if !errors.As(err, &e) {
+			t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+		}
+		if got, want := e.Path, "[]binser_test.pixel[1].Color"; got != want {
+			t.Fatalf("invalid path: got=%q, want=%q", got, want)
+		}
+		if got := c.Close(); got != err {
+			t.Fatalf("encoder not in failed state: got=%v, want=%v", got, err)
+		}
+	})
+}
+
+func TestEncoderErrorPath(t *testing.T) {
+	type palette struct {
+		Name   string
+		Pixels []pixel
+	}
+
+	buf := new(bytes.Buffer)
+	err := binser.NewEncoder(buf).Encode(palette{
+		Name:   "rgb",
+		Pixels: []pixel{{red, 1}, {color(42), 2}},
+	})
+	if !errors.Is(err, binser.ErrInvalidEnum) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrInvalidEnum)
+	}
+
+	var e *binser.EncodeError
+	if !errors.As(err, &e) {
+		t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+	}
+	if got, want := e.Path, "palette.Pixels[1].Color"; got != want {
+		t.Fatalf("invalid path: got=%q, want=%q", got, want)
+	}
+	if got, want := e.Offset, int64(buf.Len()); got != want {
+		t.Fatalf("invalid offset: got=%d, want=%d", got, want)
+	}
+}
+
+func TestEncoderNestedError(t *testing.T) {
+	type item struct {
+		ID   int32
+		Size int // not supported.
+	}
+
+	enc := binser.NewEncoder(new(bytes.Buffer))
+	err := enc.Encode(map[string]item{"a": {1, 2}})
+	if !errors.Is(err, binser.ErrTypeNotSupported) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrTypeNotSupported)
+	}
+	var e *binser.EncodeError
+	if !errors.As(err, &e) {
+		t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+	}
+	if got, want := e.Path, "map[string]binser_test.item[0].second.Size"; got != want {
+		t.Fatalf("invalid path: got=%q, want=%q", got, want)
+	}
+
+	if got := enc.Encode(int32(1)); got != err {
+		t.Fatalf("encoder not in failed state: got=%v, want=%v", got, err)
+	}
+
+	// errors before any output is written leave the encoder usable.
+	enc = binser.NewEncoder(new(bytes.Buffer))
+	err = enc.Encode(1)
+	if !errors.Is(err, binser.ErrTypeNotSupported) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrTypeNotSupported)
+	}
+
+	// errors of a partly written top-level value are sticky.
+	err = enc.Encode(blob{Count: 2, Values: []float32{1}})
+	if !errors.Is(err, binser.ErrInvalidArrayLen) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, binser.ErrInvalidArrayLen)
+	}
+	if got := enc.Encode(int32(1)); got != err {
+		t.Fatalf("encoder not in failed state: got=%v, want=%v", got, err)
+	}
+}
+
+// cancelWriter writes to w, and calls cancel once n bytes have been written.
+type cancelWriter struct {
+	w      io.Writer
+	n      int
+	cancel func()
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n -= n
+	if w.n <= 0 {
+		w.cancel()
+	}
+	return n, err
+}
+
+func TestEncoderContext(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		n    int // number of bytes written before the context is cancelled.
+		path string
+	}{
+		{name: "before", n: 0, path: "[]int32"},
+		{name: "during", n: 20000, path: "[]int32[5120]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			enc := binser.NewEncoder(&cancelWriter{w: new(bytes.Buffer), n: tc.n, cancel: cancel})
+			err := enc.EncodeContext(ctx, make([]int32, 10000))
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("invalid error: got=%v, want=%v", err, context.Canceled)
+			}
+			var e *binser.EncodeError
+			if !errors.As(err, &e) {
+				t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+			}
+			if got, want := e.Path, tc.path; got != want {
+				t.Fatalf("invalid path: got=%q, want=%q", got, want)
+			}
+
+			if got := enc.Encode(int32(1)); got != err {
+				t.Fatalf("encoder not in failed state: got=%v, want=%v", got, err)
+			}
+		})
+	}
+}
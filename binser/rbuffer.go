@@ -5,10 +5,18 @@
 package binser
 
 import (
+	"context"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
+	"math/big"
 	"reflect"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/go-boostio/boostio"
 )
 
 // A RBuffer reads values from a Boost binary serialization stream.
@@ -16,16 +24,29 @@ type RBuffer struct {
 	r    io.Reader
 	err  error
 	buf  []byte
+	skip []byte // scratch buffer of skipped bytes.
 	arch Arch
+	hdr  Header
+	opts options
+
+	off   int64           // number of bytes read.
+	depth int             // nesting depth of the value being read.
+	ctx   context.Context // context of the value being read, if any.
 
-	types registry
+	types    registry
+	versions map[reflect.Type]uint32 // versions of the user classes, in strict mode.
 }
 
 // NewRBuffer returns a new read-only buffer that reads from r.
-func NewRBuffer(r io.Reader) *RBuffer {
+func NewRBuffer(r io.Reader, opts ...Option) *RBuffer {
+	o := newOptions(opts)
+	if r != nil && o.maxBytes > 0 {
+		r = &budgetReader{r: r, n: o.maxBytes}
+	}
 	return &RBuffer{
 		r:     r,
 		buf:   make([]byte, 8),
+		opts:  o,
 		types: newRegistry(),
 	}
 }
@@ -80,12 +101,34 @@ func (r *RBuffer) ReadHeader() Header {
 	}
 
 	hdr.UnmarshalBoost(r)
-	if r.err != nil {
+	switch {
+	case r.err != nil:
 		r.err = ErrInvalidHeader
+	case r.opts.strict:
+		r.err = checkHeader(hdr)
 	}
+	r.hdr = hdr
 	return hdr
 }
 
+// checkHeader checks the version and flags of hdr are the ones of an
+// archive written by a little-endian machine with a known version of Boost.
+func checkHeader(hdr Header) error {
+	if hdr.Version == 0 || hdr.Version > boostio.Version {
+		return fmt.Errorf("%w: unknown archive version %d", ErrInvalidHeader, hdr.Version)
+	}
+	var flags [8]byte
+	binary.LittleEndian.PutUint64(flags[:], hdr.Flags)
+	switch {
+	case flags[0] != 2 && flags[0] != 4 && flags[0] != 8, // size of int
+		flags[1] != 4 && flags[1] != 8, // size of long
+		flags[2] != 4, flags[3] != 8,   // size of float, double
+		binary.LittleEndian.Uint32(flags[4:]) != 1: // little-endian
+		return fmt.Errorf("%w: unknown archive flags 0x%x", ErrInvalidHeader, hdr.Flags)
+	}
+	return nil
+}
+
 func (r *RBuffer) ReadTypeDescr(typ reflect.Type) TypeDescr {
 	if dtype, ok := r.types[typ]; ok {
 		return dtype
@@ -93,41 +136,738 @@ func (r *RBuffer) ReadTypeDescr(typ reflect.Type) TypeDescr {
 
 	var dtype TypeDescr
 	dtype.UnmarshalBoost(r)
-	switch r.err {
-	case nil:
-		r.types[typ] = dtype
-	default:
+	switch {
+	case r.err != nil:
 		r.err = ErrInvalidTypeDescr
+	case r.opts.strict && !r.checkVersion(typ, dtype.Version):
+		r.err = fmt.Errorf(
+			"%w: class version %d of %v, want %d",
+			ErrInvalidTypeDescr, dtype.Version, typ, r.versionOf(typ),
+		)
+	default:
+		r.types[typ] = dtype
 	}
 	return dtype
 }
 
+// readItemVersion reads the item_version of a collection whose elements are
+// of type et.
+// In strict mode, it must be the class version of et.
+func (r *RBuffer) readItemVersion(et reflect.Type) uint32 {
+	v := r.ReadU32()
+	if r.err == nil && r.opts.strict && !r.checkVersion(et, v) {
+		r.err = fmt.Errorf("%w: %d for %v, want %d", ErrItemVersion, v, et, r.versionOf(et))
+	}
+	return v
+}
+
+// checkVersion reports whether v is the version of the class of type rt:
+// the version the decoder reads for classes of the standard library or of
+// Boost, or the version first seen in the archive for user classes.
+func (r *RBuffer) checkVersion(rt reflect.Type, v uint32) bool {
+	if !isUserClass(rt) {
+		return v == classVersion(rt)
+	}
+	want, ok := r.versions[rt]
+	if !ok {
+		if r.versions == nil {
+			r.versions = make(map[reflect.Type]uint32)
+		}
+		r.versions[rt] = v
+		return true
+	}
+	return v == want
+}
+
+// versionOf returns the expected version of the class of type rt.
+func (r *RBuffer) versionOf(rt reflect.Type) uint32 {
+	if !isUserClass(rt) {
+		return classVersion(rt)
+	}
+	return r.versions[rt]
+}
+
 func (r *RBuffer) Read(p []byte) (int, error) {
 	if r.err != nil {
 		return 0, r.err
 	}
 	var n int
 	n, r.err = io.ReadFull(r.r, p)
+	r.off += int64(n)
 	return n, r.err
 }
 
+// readLen reads the number of elements of a collection.
+//
+// It is a 32-bits unsigned int in archives up to library version 5, and a
+// size_t afterwards.
 func (r *RBuffer) readLen() int {
+	var n uint64
+	switch {
+	case r.hdr.collectionSize32():
+		n = uint64(r.ReadU32())
+	default:
+		n = r.readSize()
+	}
+	return r.checkLen(n, r.opts.maxLen, "collection length")
+}
+
+// readStrLen reads the length of a string.
+func (r *RBuffer) readStrLen() int {
+	return r.checkLen(r.readSize(), r.opts.maxStr, "string length")
+}
+
+func (r *RBuffer) readSize() uint64 {
 	switch r.arch {
 	case 32:
-		return int(r.ReadU32())
+		return uint64(r.ReadU32())
+	default:
+		return r.ReadU64()
+	}
+}
+
+// checkLen checks the length n read from the stream fits in an int and
+// does not exceed max, if max is not zero.
+func (r *RBuffer) checkLen(n uint64, max int, what string) int {
+	switch {
+	case r.err != nil:
+		return 0
+	case n > math.MaxInt:
+		r.err = fmt.Errorf("%w: %s %d overflows int", ErrLimitExceeded, what, n)
+		return 0
+	case max > 0 && n > uint64(max):
+		r.err = fmt.Errorf("%w: %s %d > %d", ErrLimitExceeded, what, n, max)
+		return 0
+	}
+	return int(n)
+}
+
+// readBytes reads n bytes, growing its buffer as they are read.
+func (r *RBuffer) readBytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	p := make([]byte, 0, minInt(n, allocChunk))
+	for len(p) < n {
+		m := minInt(n-len(p), allocChunk)
+		p = append(p, make([]byte, m)...)
+		nn, err := io.ReadFull(r.r, p[len(p)-m:])
+		r.off += int64(nn)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			r.err = err
+			return nil
+		}
+	}
+	return p
+}
+
+// skipBytes reads and discards the next n bytes.
+func (r *RBuffer) skipBytes(n int64) {
+	for n > 0 && r.err == nil {
+		if int64(len(r.skip)) < n && len(r.skip) < allocChunk {
+			size := allocChunk
+			if n < int64(size) {
+				size = int(n)
+			}
+			r.skip = make([]byte, size)
+		}
+		p := r.skip
+		if n < int64(len(p)) {
+			p = p[:n]
+		}
+		nn, err := io.ReadFull(r.r, p)
+		r.off += int64(nn)
+		n -= int64(nn)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			r.err = err
+		}
+	}
+}
+
+// readSlice reads n values with read, growing the returned slice as the
+// values are read.
+func readSlice[T any](r *RBuffer, n int, read func() T) []T {
+	if r.err != nil {
+		return nil
+	}
+	v := make([]T, 0, minInt(n, allocChunk/8))
+	for i := 0; i < n && r.err == nil; i++ {
+		r.poll(i)
+		v = append(v, read())
+	}
+	if r.err != nil {
+		if r.err == io.EOF {
+			r.err = io.ErrUnexpectedEOF
+		}
+		return nil
+	}
+	return v
+}
+
+// enter records the reading of a nested value, checking the maximum nesting
+// depth is not exceeded. Each call to enter must be matched by a call to
+// leave.
+func (r *RBuffer) enter() {
+	r.depth++
+	if max := r.opts.maxDepth; max > 0 && r.depth > max && r.err == nil {
+		r.err = fmt.Errorf("%w: nesting depth > %d", ErrLimitExceeded, max)
+	}
+}
+
+// poll checks, every pollPeriod elements of a collection, whether the
+// context of the buffer is done, in which case its error is recorded.
+func (r *RBuffer) poll(i int) {
+	if r.ctx == nil || i%pollPeriod != 0 || r.err != nil {
+		return
+	}
+	select {
+	case <-r.ctx.Done():
+		r.err = r.ctx.Err()
 	default:
-		return int(r.ReadU64())
 	}
 }
 
+// leave records the end of the reading of a nested value.
+//
+// Reaching the end of the stream within a nested value is unexpected.
+func (r *RBuffer) leave() {
+	if r.depth > 1 && r.err == io.EOF {
+		r.err = io.ErrUnexpectedEOF
+	}
+	r.depth--
+}
+
 func (r *RBuffer) ReadString() string {
-	n := r.readLen()
+	n := r.readStrLen()
 	if n == 0 || r.err != nil {
 		return ""
 	}
-	raw := make([]byte, n)
-	_, r.err = io.ReadFull(r.r, raw)
-	return string(raw)
+	return string(r.readBytes(n))
+}
+
+// ReadBitset reads a std::bitset<n>.
+func (r *RBuffer) ReadBitset(n int) boostio.Bitset {
+	_ = r.ReadTypeDescr(bitsetOf(n))
+	str := r.ReadString()
+	if r.err != nil {
+		return *boostio.NewBitset(n)
+	}
+	b, err := boostio.ParseBitset(str)
+	if err != nil || b.Len() != n {
+		r.err = ErrInvalidBitset
+		return *boostio.NewBitset(n)
+	}
+	return *b
+}
+
+// ReadBinaryObject reads a boost::serialization::binary_object of n bytes.
+//
+// A binary_object is stored as raw bytes, with no count: its size is known
+// from the surrounding data.
+func (r *RBuffer) ReadBinaryObject(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 {
+		r.err = ErrInvalidArrayLen
+		return nil
+	}
+	n = r.checkLen(uint64(n), r.opts.maxStr, "binary object length")
+	return r.readBytes(n)
+}
+
+// ReadEnum reads the value of a C++ enum.
+//
+// Enums are stored as a C++ int, whose size is recorded in the archive
+// header.
+func (r *RBuffer) ReadEnum() int64 {
+	switch r.sizeofInt() {
+	case 2:
+		return int64(r.ReadI16())
+	case 8:
+		return r.ReadI64()
+	default:
+		return int64(r.ReadI32())
+	}
+}
+
+// ReadVectorBool reads a std::vector<bool>.
+//
+// A std::vector<bool> is stored as its number of elements, followed by one
+// bool per element. Unlike other collections, it never has an item_version.
+func (r *RBuffer) ReadVectorBool() []bool {
+	_ = r.ReadTypeDescr(boolsType)
+	return readSlice(r, r.readLen(), r.ReadBool)
+}
+
+// ReadDynamicBitset reads a boost::dynamic_bitset<>.
+//
+// The size of the blocks is the size of a C++ long, as recorded in the
+// archive header.
+func (r *RBuffer) ReadDynamicBitset() boostio.DynamicBitset {
+	_ = r.ReadTypeDescr(dynamicBitsetType)
+	n := r.checkLen(r.readSize(), r.opts.maxLen, "number of bits")
+	nb := r.readLen()
+	if r.err != nil {
+		return boostio.DynamicBitset{}
+	}
+
+	var blocks []uint64
+	switch r.sizeofLong() {
+	case 4:
+		if nb != (n+31)/32 {
+			r.err = ErrInvalidBitset
+			return boostio.DynamicBitset{}
+		}
+		words := readSlice(r, nb, r.ReadU32)
+		blocks = make([]uint64, (len(words)+1)/2)
+		for i, w := range words {
+			blocks[i/2] |= uint64(w) << (32 * uint(i%2))
+		}
+	default:
+		if nb != (n+63)/64 {
+			r.err = ErrInvalidBitset
+			return boostio.DynamicBitset{}
+		}
+		blocks = readSlice(r, nb, r.ReadU64)
+	}
+	if r.err != nil {
+		return boostio.DynamicBitset{}
+	}
+	return *boostio.NewDynamicBitset(n, blocks...)
+}
+
+// ReadWString reads a std::wstring and converts it to UTF-8.
+//
+// The size of a C++ wchar_t is taken from the WithWCharSize option.
+// Otherwise, it is guessed from the archive header: 2 (UTF-16) for 64-bits
+// archives with a 4-bytes long (as written on Windows), 4 (UTF-32) for
+// all the others.
+func (r *RBuffer) ReadWString() string {
+	n := r.readStrLen()
+	if n == 0 || r.err != nil {
+		return ""
+	}
+
+	var o strings.Builder
+	switch r.sizeofWChar() {
+	case 2:
+		units := readSlice(r, n, r.ReadU16)
+		if r.err != nil {
+			return ""
+		}
+		for i := 0; i < n; i++ {
+			c := rune(units[i])
+			if utf16.IsSurrogate(c) {
+				if i+1 == n {
+					r.err = ErrInvalidCodePoint
+					return ""
+				}
+				i++
+				c = utf16.DecodeRune(c, rune(units[i]))
+				if c == utf8.RuneError {
+					r.err = ErrInvalidCodePoint
+					return ""
+				}
+			}
+			o.WriteRune(c)
+		}
+	default:
+		for i := 0; i < n && r.err == nil; i++ {
+			c := rune(r.ReadU32())
+			if !utf8.ValidRune(c) {
+				r.err = ErrInvalidCodePoint
+				return ""
+			}
+			o.WriteRune(c)
+		}
+	}
+	if r.err != nil {
+		return ""
+	}
+	return o.String()
+}
+
+// ReadLongDouble reads a C++ long double.
+//
+// The size of a C++ long double is taken from the WithLongDoubleSize option.
+// Otherwise, it is guessed from the archive header: 8 for 64-bits archives
+// with a 4-bytes long (as written on Windows), 16 for the other 64-bits
+// archives and 12 for 32-bits ones.
+func (r *RBuffer) ReadLongDouble() boostio.LongDouble {
+	switch n := r.sizeofLongDouble(); n {
+	case 8:
+		return boostio.NewLongDouble(r.ReadF64())
+	default:
+		var v boostio.LongDouble
+		v.Mantissa = r.ReadU64()
+		v.SignExp = r.ReadU16()
+		r.load(n - 10) // padding
+		if r.err != nil {
+			return boostio.LongDouble{}
+		}
+		return v
+	}
+}
+
+// ReadBigInt reads a boost::multiprecision::cpp_int.
+//
+// A cpp_int is written as its sign, its number of limbs and its limbs,
+// the least significant first. Limbs are 32-bits wide in 32-bits archives
+// and 64-bits wide otherwise.
+func (r *RBuffer) ReadBigInt() *big.Int {
+	_ = r.ReadTypeDescr(bigIntType)
+	_ = r.ReadTypeDescr(cppIntType)
+	neg := r.ReadBool()
+	n := r.checkLen(r.readSize(), r.opts.maxLen, "number of limbs")
+	if r.err != nil {
+		return new(big.Int)
+	}
+
+	sz := r.sizeofLimb()
+	if n > math.MaxInt/sz {
+		r.err = fmt.Errorf("%w: number of limbs %d overflows int", ErrLimitExceeded, n)
+		return new(big.Int)
+	}
+	limbs := r.readBytes(n * sz)
+	if r.err != nil {
+		return new(big.Int)
+	}
+	raw := make([]byte, len(limbs))
+	for i := 0; i < n; i++ {
+		beg := len(raw) - (i+1)*sz
+		switch sz {
+		case 4:
+			binary.BigEndian.PutUint32(raw[beg:], binary.LittleEndian.Uint32(limbs[i*sz:]))
+		default:
+			binary.BigEndian.PutUint64(raw[beg:], binary.LittleEndian.Uint64(limbs[i*sz:]))
+		}
+	}
+
+	v := new(big.Int).SetBytes(raw)
+	if neg {
+		v.Neg(v)
+	}
+	return v
+}
+
+// ReadBigFloat reads a boost::multiprecision::cpp_dec_float<Digits10>.
+//
+// Digits10 is taken from the WithDecFloatDigits option (default: 50).
+func (r *RBuffer) ReadBigFloat() *big.Float {
+	_ = r.ReadTypeDescr(bigFloatType)
+	_ = r.ReadTypeDescr(cppDecFloatType)
+	v := boostio.DecFloat{Data: make([]uint32, boostio.DecFloatLen(r.opts.dec10))}
+	for i := range v.Data {
+		v.Data[i] = r.ReadU32()
+	}
+	v.Exp = r.ReadI32()
+	v.Neg = r.ReadBool()
+	v.Class = r.ReadI32()
+	v.Prec = r.ReadI32()
+	if r.err != nil {
+		return new(big.Float)
+	}
+
+	f, err := v.BigFloat()
+	if err != nil {
+		r.err = ErrInvalidNumber
+		return new(big.Float)
+	}
+	return f
+}
+
+// ReadBigRat reads a boost::rational<cpp_int>, written as its numerator
+// and denominator.
+func (r *RBuffer) ReadBigRat() *big.Rat {
+	_ = r.ReadTypeDescr(bigRatType)
+	num := r.ReadBigInt()
+	den := r.ReadBigInt()
+	if r.err != nil {
+		return new(big.Rat)
+	}
+	if den.Sign() == 0 {
+		r.err = ErrInvalidNumber
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(num, den)
+}
+
+// ReadUUID reads a boost::uuids::uuid, held as its 16 raw bytes.
+func (r *RBuffer) ReadUUID() boostio.UUID {
+	var u boostio.UUID
+	r.load(8)
+	copy(u[:8], r.buf)
+	r.load(8)
+	copy(u[8:], r.buf)
+	if r.err != nil {
+		return boostio.UUID{}
+	}
+	return u
+}
+
+// ReadVector reads a dense boost::numeric::ublas::vector<double>.
+func (r *RBuffer) ReadVector() boostio.Vector {
+	_ = r.ReadTypeDescr(vectorType)
+	return boostio.Vector(r.readUnboundedArray())
+}
+
+// ReadMatrix reads a dense boost::numeric::ublas::matrix<double>, stored in
+// column-major order if colMajor is true, in row-major order otherwise.
+func (r *RBuffer) ReadMatrix(colMajor bool) boostio.Matrix {
+	switch colMajor {
+	case true:
+		_ = r.ReadTypeDescr(colMajorMatrixType)
+	default:
+		_ = r.ReadTypeDescr(matrixType)
+	}
+	rows := r.readLen()
+	cols := r.readLen()
+	data := r.readUnboundedArray()
+	if r.err != nil {
+		return boostio.Matrix{ColMajor: colMajor}
+	}
+	// rows*cols may overflow.
+	if (cols != 0 && rows > len(data)/cols) || len(data) != rows*cols {
+		r.err = ErrInvalidMatrix
+		return boostio.Matrix{ColMajor: colMajor}
+	}
+	return boostio.Matrix{Rows: rows, Cols: cols, ColMajor: colMajor, Data: data}
+}
+
+// readUnboundedArray reads a boost::numeric::ublas::unbounded_array<double>.
+func (r *RBuffer) readUnboundedArray() []float64 {
+	_ = r.ReadTypeDescr(unboundedArrayType)
+	return r.readF64s(r.readLen())
+}
+
+// readF64s reads n float64 values in one go.
+func (r *RBuffer) readF64s(n int) []float64 {
+	if r.err != nil {
+		return nil
+	}
+	if n > math.MaxInt/8 {
+		r.err = fmt.Errorf("%w: collection length %d overflows int", ErrLimitExceeded, n)
+		return nil
+	}
+	raw := r.readBytes(8 * n)
+	if r.err != nil {
+		return nil
+	}
+	v := make([]float64, n)
+	for i := range v {
+		v[i] = math.Float64frombits(binary.LittleEndian.Uint64(raw[8*i:]))
+	}
+	return v
+}
+
+// ReadHistogram reads a boost::histogram::histogram with a std::vector of
+// axis::variant axes, whose alternatives are listed by variant (default:
+// boostio.DefaultAxisVariant), and with a dense int storage or, if
+// weighted is true, a dense weight storage.
+func (r *RBuffer) ReadHistogram(variant []boostio.AxisKind, weighted bool) boostio.Histogram {
+	h := boostio.Histogram{Variant: variant, Weighted: weighted}
+	switch weighted {
+	case true:
+		_ = r.ReadTypeDescr(weightedHistogramType)
+	default:
+		_ = r.ReadTypeDescr(histogramType)
+	}
+
+	_ = r.ReadTypeDescr(axesType)
+	n := r.readLen()
+	r.readItemVersion(axisVariantType)
+	kinds := h.AxisVariant()
+	for i := 0; i < n && r.err == nil; i++ {
+		_ = r.ReadTypeDescr(axisVariantType)
+		_ = r.ReadTypeDescr(axisVariantProxyType)
+		which := int(r.ReadI32())
+		if r.err != nil {
+			break
+		}
+		if which < 0 || which >= len(kinds) {
+			r.err = ErrInvalidHistogram
+			break
+		}
+		h.Axes = append(h.Axes, r.readAxis(kinds[which]))
+	}
+
+	switch weighted {
+	case true:
+		_ = r.ReadTypeDescr(weightStorageType)
+		_ = r.ReadTypeDescr(weightStorageImplType)
+		_ = r.ReadTypeDescr(weightedSumsType)
+		n := r.readLen()
+		r.readItemVersion(weightedSumType)
+		for i := 0; i < n && r.err == nil; i++ {
+			_ = r.ReadTypeDescr(weightedSumType)
+			h.Weights = append(h.Weights, boostio.WeightedSum{
+				SumOfWeights:        r.ReadF64(),
+				SumOfWeightsSquared: r.ReadF64(),
+			})
+		}
+	default:
+		_ = r.ReadTypeDescr(intStorageType)
+		_ = r.ReadTypeDescr(intStorageImplType)
+		n := r.readLen()
+		for i := 0; i < n && r.err == nil; i++ {
+			h.Counts = append(h.Counts, r.ReadI32())
+		}
+	}
+
+	if r.err != nil {
+		return boostio.Histogram{Variant: variant, Weighted: weighted}
+	}
+	return h
+}
+
+func (r *RBuffer) readAxis(kind boostio.AxisKind) boostio.Axis {
+	switch kind {
+	case boostio.RegularAxisKind:
+		_ = r.ReadTypeDescr(regularAxisType)
+		_ = r.ReadTypeDescr(transformIDType)
+		var axis boostio.RegularAxis
+		axis.Size = r.ReadI32()
+		axis.Meta = r.ReadString()
+		axis.Min = r.ReadF64()
+		axis.Delta = r.ReadF64()
+		return axis
+	case boostio.VariableAxisKind:
+		_ = r.ReadTypeDescr(variableAxisType)
+		var axis boostio.VariableAxis
+		axis.Edges = r.readF64s(r.readLen())
+		axis.Meta = r.ReadString()
+		return axis
+	case boostio.IntegerAxisKind:
+		_ = r.ReadTypeDescr(integerAxisType)
+		var axis boostio.IntegerAxis
+		axis.Size = r.ReadI32()
+		axis.Meta = r.ReadString()
+		axis.Min = r.ReadI32()
+		return axis
+	case boostio.CategoryAxisKind:
+		_ = r.ReadTypeDescr(categoryAxisType)
+		var axis boostio.CategoryAxis
+		n := r.readLen()
+		for i := 0; i < n && r.err == nil; i++ {
+			axis.Values = append(axis.Values, r.ReadI32())
+		}
+		axis.Meta = r.ReadString()
+		return axis
+	case boostio.StrCategoryAxisKind:
+		_ = r.ReadTypeDescr(strCategoryType)
+		_ = r.ReadTypeDescr(stringsType)
+		var axis boostio.StrCategoryAxis
+		n := r.readLen()
+		r.readItemVersion(stringsType.Elem())
+		for i := 0; i < n && r.err == nil; i++ {
+			axis.Values = append(axis.Values, r.ReadString())
+		}
+		axis.Meta = r.ReadString()
+		return axis
+	}
+	r.err = ErrInvalidHistogram
+	return nil
+}
+
+// readNodePosition reads the position of a node of a multi_index_container,
+// and returns its object ID if nodes are tracked.
+func (r *RBuffer) readNodePosition(node reflect.Type, tracked bool) uint32 {
+	_ = r.ReadTypeDescr(node)
+	if !tracked {
+		return 0
+	}
+	return r.ReadU32()
+}
+
+// ReadPTree reads a boost::property_tree::ptree.
+func (r *RBuffer) ReadPTree() boostio.PTree {
+	r.enter()
+	defer r.leave()
+
+	var t boostio.PTree
+	_ = r.ReadTypeDescr(ptreeType)
+	n := r.readLen()
+	if n > 0 {
+		r.readItemVersion(ptreeItemType)
+	}
+	for i := 0; i < n && r.err == nil; i++ {
+		_ = r.ReadTypeDescr(ptreeItemType)
+		key := r.ReadString()
+		t.Add(key, r.ReadPTree())
+	}
+	t.Data = r.ReadString()
+	if r.err != nil {
+		return boostio.PTree{}
+	}
+	return t
+}
+
+// ReadDate reads a boost::gregorian::date.
+func (r *RBuffer) ReadDate() boostio.Date {
+	_ = r.ReadTypeDescr(dateType)
+	str := r.ReadString()
+	if r.err != nil {
+		return boostio.Date{}
+	}
+	v, err := boostio.ParseDate(str)
+	if err != nil {
+		r.err = ErrInvalidDateTime
+		return boostio.Date{}
+	}
+	return v
+}
+
+// ReadPTime reads a boost::posix_time::ptime.
+func (r *RBuffer) ReadPTime() boostio.PTime {
+	_ = r.ReadTypeDescr(ptimeType)
+	d := r.ReadDate()
+	if r.err != nil || d.Special != boostio.NotSpecial {
+		return boostio.PTime{Special: d.Special}
+	}
+	td := r.ReadTimeDuration()
+	if r.err != nil || td.Special != boostio.NotSpecial {
+		return boostio.PTime{Special: td.Special}
+	}
+	return boostio.PTime{Time: d.Time.Add(td.Duration)}
+}
+
+// ReadTimeDuration reads a boost::posix_time::time_duration.
+func (r *RBuffer) ReadTimeDuration() boostio.TimeDuration {
+	dt := r.ReadTypeDescr(timeDurationType)
+	if r.ReadBool() {
+		v := boostio.ParseSpecial(r.ReadString())
+		if r.err == nil && v == boostio.NotSpecial {
+			r.err = ErrInvalidDateTime
+		}
+		if r.err != nil {
+			return boostio.TimeDuration{}
+		}
+		return boostio.TimeDuration{Special: v}
+	}
+
+	var h, m, s int64
+	switch dt.Version {
+	case 0:
+		h = int64(r.ReadI32())
+		m = int64(r.ReadI32())
+		s = int64(r.ReadI32())
+	default:
+		h = r.ReadI64()
+		m = r.ReadI64()
+		s = r.ReadI64()
+	}
+	us := r.ReadI64()
+	if r.err != nil {
+		return boostio.TimeDuration{}
+	}
+	return boostio.TimeDuration{Duration: makeDuration(h, m, s, us)}
 }
 
 func (r *RBuffer) ReadBool() bool {
@@ -205,12 +945,65 @@ func (r *RBuffer) ReadC128() complex128 {
 	return complex(v0, v1)
 }
 
+func (r *RBuffer) sizeofInt() int {
+	if n := r.hdr.sizeofInt(); n != 0 {
+		return n
+	}
+	return 4
+}
+
+func (r *RBuffer) sizeofLong() int {
+	if n := r.hdr.sizeofLong(); n != 0 {
+		return n
+	}
+	switch r.arch {
+	case 32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func (r *RBuffer) sizeofWChar() int {
+	switch {
+	case r.opts.wchar != 0:
+		return r.opts.wchar
+	case r.arch == 64 && r.hdr.sizeofLong() == 4:
+		return 2
+	default:
+		return 4
+	}
+}
+
+func (r *RBuffer) sizeofLongDouble() int {
+	switch {
+	case r.opts.ldbl != 0:
+		return r.opts.ldbl
+	case r.arch == 32:
+		return 12
+	case r.hdr.sizeofLong() == 4:
+		return 8
+	default:
+		return 16
+	}
+}
+
+func (r *RBuffer) sizeofLimb() int {
+	switch r.arch {
+	case 32:
+		return 4
+	default:
+		return 8
+	}
+}
+
 func (r *RBuffer) load(n int) {
 	if r.err != nil {
 		return
 	}
 
 	nn, err := io.ReadFull(r.r, r.buf[:n])
+	r.off += int64(nn)
 	if err != nil {
 		r.err = err
 		return
@@ -150,5 +150,6 @@ int main()
   oa << animal("pet", 4, 1);
   oa << std::vector<std::string>({"s1", "s2", "s3"});
   oa << std::vector<animal>({animal("tiger",4,1), animal("monkey",4,1)});
+  oa << std::vector<bool>({true, false, true});
 }
 `
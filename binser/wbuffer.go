@@ -5,10 +5,16 @@
 package binser
 
 import (
+	"context"
 	"encoding/binary"
 	"io"
 	"math"
+	"math/big"
 	"reflect"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/go-boostio/boostio"
 )
 
 type WBuffer struct {
@@ -16,36 +22,52 @@ type WBuffer struct {
 	err  error
 	buf  []byte
 	arch Arch
+	hdr  Header
+	opts options
+
+	off int64           // number of bytes written.
+	ctx context.Context // context of the value being written, if any.
 
 	types registry
+	cids  map[reflect.Type]int16 // class IDs, in order of appearance.
+	oids  uint32                 // number of tracked objects.
 }
 
-func NewWBuffer(w io.Writer) *WBuffer {
-	return newWBuffer(w, Arch64)
+func NewWBuffer(w io.Writer, opts ...Option) *WBuffer {
+	return newWBuffer(w, Arch64, opts)
 }
 
-func newWBuffer(w io.Writer, arch Arch) *WBuffer {
+func newWBuffer(w io.Writer, arch Arch, opts []Option) *WBuffer {
 	return &WBuffer{
 		w:     w,
 		buf:   make([]byte, 8),
 		types: newRegistry(),
+		cids:  make(map[reflect.Type]int16),
 		arch:  arch,
+		opts:  newOptions(opts),
 	}
 }
 
 func (w *WBuffer) Err() error { return w.err }
 
 func (w *WBuffer) WriteHeader(hdr Header) error {
+	w.hdr = hdr
 	w.err = hdr.MarshalBoost(w)
 	return w.err
 }
 
 func (w *WBuffer) WriteTypeDescr(rt reflect.Type) error {
-	dt, ok := w.types[rt]
-	if ok {
+	return w.writeTypeDescr(rt, TypeDescr{Version: classVersions[rt], Flags: 0})
+}
+
+// writeTypeDescr writes the class information dt of the given type, the
+// first time that type is seen.
+func (w *WBuffer) writeTypeDescr(rt reflect.Type, dt TypeDescr) error {
+	if _, ok := w.types[rt]; ok {
 		return nil
 	}
-	dt = TypeDescr{Version: 0, Flags: 0}
+	// only the types with class information written get a class ID.
+	w.cids[rt] = int16(len(w.cids))
 	w.types[rt] = dt
 	w.err = dt.MarshalBoost(w)
 	return w.err
@@ -57,10 +79,33 @@ func (w *WBuffer) Write(p []byte) (int, error) {
 	}
 	var n int
 	n, w.err = w.w.Write(p)
+	w.off += int64(n)
 	return n, w.err
 }
 
+// poll checks, every pollPeriod elements of a collection, whether the
+// context of the buffer is done, in which case its error is recorded.
+func (w *WBuffer) poll(i int) {
+	if w.ctx == nil || i%pollPeriod != 0 || w.err != nil {
+		return
+	}
+	select {
+	case <-w.ctx.Done():
+		w.err = w.ctx.Err()
+	default:
+	}
+}
+
+// writeLen writes the number of elements of a collection.
 func (w *WBuffer) writeLen(n int) error {
+	if w.hdr.collectionSize32() {
+		return w.WriteU32(uint32(n))
+	}
+	return w.writeSize(n)
+}
+
+// writeSize writes a size_t.
+func (w *WBuffer) writeSize(n int) error {
 	switch w.arch {
 	case 32:
 		return w.WriteU32(uint32(n))
@@ -69,12 +114,457 @@ func (w *WBuffer) writeLen(n int) error {
 	}
 }
 
+// patchLen overwrites the number of elements of a collection, written at
+// the offset pos of the output ws of the buffer.
+func (w *WBuffer) patchLen(ws io.WriteSeeker, pos int64, n int) error {
+	if w.err != nil {
+		return w.err
+	}
+	var p []byte
+	switch {
+	case w.arch == 32 || w.hdr.collectionSize32():
+		p = w.buf[:4]
+		binary.LittleEndian.PutUint32(p, uint32(n))
+	default:
+		p = w.buf[:8]
+		binary.LittleEndian.PutUint64(p, uint64(n))
+	}
+	end, err := ws.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = ws.Seek(pos, io.SeekStart)
+	}
+	if err == nil {
+		_, err = ws.Write(p)
+	}
+	if err == nil {
+		_, err = ws.Seek(end, io.SeekStart)
+	}
+	w.err = err
+	return w.err
+}
+
 func (w *WBuffer) WriteString(v string) error {
 	if w.err != nil {
 		return w.err
 	}
+	w.writeSize(len(v))
+	var n int
+	n, w.err = w.w.Write([]byte(v))
+	w.off += int64(n)
+	return w.err
+}
+
+// WriteBitset writes a std::bitset<N>, where N is the length of b.
+func (w *WBuffer) WriteBitset(b *boostio.Bitset) error {
+	w.WriteTypeDescr(bitsetOf(b.Len()))
+	return w.WriteString(b.String())
+}
+
+// WriteBinaryObject writes p as a boost::serialization::binary_object.
+//
+// A binary_object is stored as raw bytes, with no count: its size must be
+// known from the surrounding data.
+func (w *WBuffer) WriteBinaryObject(p []byte) error {
+	_, err := w.Write(p)
+	return err
+}
+
+// WriteEnum writes the value of a C++ enum.
+//
+// Enums are stored as a C++ int, whose size is recorded in the archive
+// header.
+func (w *WBuffer) WriteEnum(v int64) error {
+	if w.err != nil {
+		return w.err
+	}
+	switch w.sizeofInt() {
+	case 2:
+		if v < math.MinInt16 || v > math.MaxInt16 {
+			w.err = ErrInvalidEnum
+			return w.err
+		}
+		return w.WriteI16(int16(v))
+	case 8:
+		return w.WriteI64(v)
+	default:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			w.err = ErrInvalidEnum
+			return w.err
+		}
+		return w.WriteI32(int32(v))
+	}
+}
+
+// WriteVectorBool writes v as a std::vector<bool>.
+//
+// A std::vector<bool> is stored as its number of elements, followed by one
+// bool per element. Unlike other collections, it never has an item_version.
+func (w *WBuffer) WriteVectorBool(v []bool) error {
+	w.WriteTypeDescr(boolsType)
+	w.writeLen(len(v))
+	for _, b := range v {
+		w.WriteBool(b)
+	}
+	return w.err
+}
+
+// WriteDynamicBitset writes a boost::dynamic_bitset<>.
+//
+// The size of the blocks is the size of a C++ long, as recorded in the
+// archive header.
+func (w *WBuffer) WriteDynamicBitset(b *boostio.DynamicBitset) error {
+	w.WriteTypeDescr(dynamicBitsetType)
+	w.writeSize(b.Len())
+	blocks := b.Blocks()
+	switch w.sizeofLong() {
+	case 4:
+		n := (b.Len() + 31) / 32
+		w.writeLen(n)
+		for i := 0; i < n; i++ {
+			w.WriteU32(uint32(blocks[i/2] >> (32 * uint(i%2))))
+		}
+	default:
+		w.writeLen(len(blocks))
+		for _, v := range blocks {
+			w.WriteU64(v)
+		}
+	}
+	return w.err
+}
+
+// WriteWString writes a UTF-8 string as a std::wstring.
+//
+// The size of a C++ wchar_t is taken from the WithWCharSize option.
+// Otherwise, it is guessed from the archive header: 2 (UTF-16) for 64-bits
+// archives with a 4-bytes long (as written on Windows), 4 (UTF-32) for
+// all the others.
+func (w *WBuffer) WriteWString(v string) error {
+	if w.err != nil {
+		return w.err
+	}
+	if !utf8.ValidString(v) {
+		w.err = ErrInvalidCodePoint
+		return w.err
+	}
+	switch w.sizeofWChar() {
+	case 2:
+		units := utf16.Encode([]rune(v))
+		w.writeSize(len(units))
+		for _, c := range units {
+			w.WriteU16(c)
+		}
+	default:
+		w.writeSize(utf8.RuneCountInString(v))
+		for _, c := range v {
+			w.WriteU32(uint32(c))
+		}
+	}
+	return w.err
+}
+
+// WriteLongDouble writes a C++ long double.
+//
+// The size of a C++ long double is taken from the WithLongDoubleSize option.
+// Otherwise, it is guessed from the archive header: 8 for 64-bits archives
+// with a 4-bytes long (as written on Windows), 16 for the other 64-bits
+// archives and 12 for 32-bits ones.
+func (w *WBuffer) WriteLongDouble(v boostio.LongDouble) error {
+	if w.err != nil {
+		return w.err
+	}
+	switch n := w.sizeofLongDouble(); n {
+	case 8:
+		w.WriteF64(v.Float64())
+	default:
+		w.WriteU64(v.Mantissa)
+		w.WriteU16(v.SignExp)
+		for i := range w.buf[:n-10] {
+			w.buf[i] = 0
+		}
+		w.write(n - 10) // padding
+	}
+	return w.err
+}
+
+// WriteBigInt writes v as a boost::multiprecision::cpp_int.
+//
+// A cpp_int is written as its sign, its number of limbs and its limbs,
+// the least significant first. Limbs are 32-bits wide in 32-bits archives
+// and 64-bits wide otherwise.
+func (w *WBuffer) WriteBigInt(v *big.Int) error {
+	if v == nil {
+		v = new(big.Int)
+	}
+	w.WriteTypeDescr(bigIntType)
+	w.WriteTypeDescr(cppIntType)
+	w.WriteBool(v.Sign() < 0)
+
+	var (
+		sz  = w.sizeofLimb()
+		raw = v.Bytes()
+		n   = (len(raw) + sz - 1) / sz
+	)
+	if n == 0 {
+		n = 1 // a zero cpp_int holds one limb.
+	}
+	raw = append(make([]byte, n*sz-len(raw)), raw...)
+	w.writeSize(n)
+	for i := 0; i < n; i++ {
+		beg := len(raw) - (i+1)*sz
+		switch sz {
+		case 4:
+			w.WriteU32(binary.BigEndian.Uint32(raw[beg:]))
+		default:
+			w.WriteU64(binary.BigEndian.Uint64(raw[beg:]))
+		}
+	}
+	return w.err
+}
+
+// WriteBigFloat writes v as a boost::multiprecision::cpp_dec_float<Digits10>.
+//
+// Digits10 is taken from the WithDecFloatDigits option (default: 50).
+func (w *WBuffer) WriteBigFloat(v *big.Float) error {
+	if v == nil {
+		v = new(big.Float)
+	}
+	w.WriteTypeDescr(bigFloatType)
+	w.WriteTypeDescr(cppDecFloatType)
+	d := boostio.NewDecFloat(v, w.opts.dec10)
+	for _, v := range d.Data {
+		w.WriteU32(v)
+	}
+	w.WriteI32(d.Exp)
+	w.WriteBool(d.Neg)
+	w.WriteI32(d.Class)
+	w.WriteI32(d.Prec)
+	return w.err
+}
+
+// WriteBigRat writes v as a boost::rational<cpp_int>, written as its
+// numerator and denominator.
+func (w *WBuffer) WriteBigRat(v *big.Rat) error {
+	if v == nil {
+		v = new(big.Rat)
+	}
+	w.WriteTypeDescr(bigRatType)
+	w.WriteBigInt(v.Num())
+	w.WriteBigInt(v.Denom())
+	return w.err
+}
+
+// WriteUUID writes a boost::uuids::uuid, as its 16 raw bytes.
+func (w *WBuffer) WriteUUID(v boostio.UUID) error {
+	w.Write(v[:])
+	return w.err
+}
+
+// WriteVector writes a dense boost::numeric::ublas::vector<double>.
+func (w *WBuffer) WriteVector(v boostio.Vector) error {
+	w.WriteTypeDescr(vectorType)
+	w.writeUnboundedArray(v)
+	return w.err
+}
+
+// WriteMatrix writes a dense boost::numeric::ublas::matrix<double>.
+func (w *WBuffer) WriteMatrix(m *boostio.Matrix) error {
+	if len(m.Data) != m.Rows*m.Cols {
+		w.err = ErrInvalidMatrix
+		return w.err
+	}
+	switch m.ColMajor {
+	case true:
+		w.WriteTypeDescr(colMajorMatrixType)
+	default:
+		w.WriteTypeDescr(matrixType)
+	}
+	w.writeLen(m.Rows)
+	w.writeLen(m.Cols)
+	w.writeUnboundedArray(m.Data)
+	return w.err
+}
+
+// writeUnboundedArray writes a boost::numeric::ublas::unbounded_array<double>.
+func (w *WBuffer) writeUnboundedArray(v []float64) {
+	w.WriteTypeDescr(unboundedArrayType)
 	w.writeLen(len(v))
-	_, w.err = w.w.Write([]byte(v))
+	if w.err != nil || len(v) == 0 {
+		return
+	}
+	raw := make([]byte, 8*len(v))
+	for i, v := range v {
+		binary.LittleEndian.PutUint64(raw[8*i:], math.Float64bits(v))
+	}
+	w.Write(raw)
+}
+
+// WriteHistogram writes a boost::histogram::histogram with a std::vector of
+// axis::variant axes and a dense int or weight storage.
+func (w *WBuffer) WriteHistogram(h *boostio.Histogram) error {
+	switch h.Weighted {
+	case true:
+		w.WriteTypeDescr(weightedHistogramType)
+	default:
+		w.WriteTypeDescr(histogramType)
+	}
+
+	w.WriteTypeDescr(axesType)
+	w.writeLen(len(h.Axes))
+	w.WriteU32(0) // item_version
+	for _, axis := range h.Axes {
+		which := h.Which(axis)
+		if which < 0 {
+			w.err = ErrInvalidHistogram
+			return w.err
+		}
+		w.WriteTypeDescr(axisVariantType)
+		w.WriteTypeDescr(axisVariantProxyType)
+		w.WriteI32(int32(which))
+		w.writeAxis(axis)
+	}
+
+	switch h.Weighted {
+	case true:
+		w.WriteTypeDescr(weightStorageType)
+		w.WriteTypeDescr(weightStorageImplType)
+		w.WriteTypeDescr(weightedSumsType)
+		w.writeLen(len(h.Weights))
+		w.WriteU32(0) // item_version
+		for _, v := range h.Weights {
+			w.WriteTypeDescr(weightedSumType)
+			w.WriteF64(v.SumOfWeights)
+			w.WriteF64(v.SumOfWeightsSquared)
+		}
+	default:
+		w.WriteTypeDescr(intStorageType)
+		w.WriteTypeDescr(intStorageImplType)
+		w.writeLen(len(h.Counts))
+		for _, v := range h.Counts {
+			w.WriteI32(v)
+		}
+	}
+	return w.err
+}
+
+func (w *WBuffer) writeAxis(axis boostio.Axis) {
+	switch axis := axis.(type) {
+	case boostio.RegularAxis:
+		w.WriteTypeDescr(regularAxisType)
+		w.WriteTypeDescr(transformIDType)
+		w.WriteI32(axis.Size)
+		w.WriteString(axis.Meta)
+		w.WriteF64(axis.Min)
+		w.WriteF64(axis.Delta)
+	case boostio.VariableAxis:
+		w.WriteTypeDescr(variableAxisType)
+		w.writeLen(len(axis.Edges))
+		for _, v := range axis.Edges {
+			w.WriteF64(v)
+		}
+		w.WriteString(axis.Meta)
+	case boostio.IntegerAxis:
+		w.WriteTypeDescr(integerAxisType)
+		w.WriteI32(axis.Size)
+		w.WriteString(axis.Meta)
+		w.WriteI32(axis.Min)
+	case boostio.CategoryAxis:
+		w.WriteTypeDescr(categoryAxisType)
+		w.writeLen(len(axis.Values))
+		for _, v := range axis.Values {
+			w.WriteI32(v)
+		}
+		w.WriteString(axis.Meta)
+	case boostio.StrCategoryAxis:
+		w.WriteTypeDescr(strCategoryType)
+		w.WriteTypeDescr(stringsType)
+		w.writeLen(len(axis.Values))
+		w.WriteU32(0) // item_version
+		for _, v := range axis.Values {
+			w.WriteString(v)
+		}
+		w.WriteString(axis.Meta)
+	default:
+		w.err = ErrInvalidHistogram
+	}
+}
+
+// writeNodePosition writes the position of a node of a multi_index_container,
+// and returns its object ID if nodes are tracked.
+func (w *WBuffer) writeNodePosition(node reflect.Type, tracked bool) uint32 {
+	if !tracked {
+		w.WriteTypeDescr(node)
+		return 0
+	}
+	w.writeTypeDescr(node, TypeDescr{Flags: 1})
+	oid := w.oids
+	w.oids++
+	w.WriteU32(oid)
+	return oid
+}
+
+// WritePTree writes a boost::property_tree::ptree.
+func (w *WBuffer) WritePTree(t *boostio.PTree) error {
+	w.WriteTypeDescr(ptreeType)
+	w.writeLen(len(t.Children))
+	if len(t.Children) > 0 {
+		w.WriteU32(0) // item_version
+	}
+	for i := range t.Children {
+		child := &t.Children[i]
+		w.WriteTypeDescr(ptreeItemType)
+		w.WriteString(child.First)
+		w.WritePTree(&child.Second)
+	}
+	w.WriteString(t.Data)
+	return w.err
+}
+
+// WriteDate writes a boost::gregorian::date.
+// A zero date is written as not_a_date_time.
+func (w *WBuffer) WriteDate(v boostio.Date) error {
+	if v.Special == boostio.NotSpecial && v.Time.IsZero() {
+		v.Special = boostio.NotADateTime
+	}
+	w.WriteTypeDescr(dateType)
+	w.WriteString(v.String())
+	return w.err
+}
+
+// WritePTime writes a boost::posix_time::ptime, truncated to the
+// microsecond.
+// A zero time is written as not_a_date_time.
+func (w *WBuffer) WritePTime(v boostio.PTime) error {
+	if v.Special == boostio.NotSpecial && v.Time.IsZero() {
+		v.Special = boostio.NotADateTime
+	}
+	w.WriteTypeDescr(ptimeType)
+	if v.Special != boostio.NotSpecial {
+		w.WriteDate(boostio.Date{Special: v.Special})
+		return w.err
+	}
+
+	t := v.Time.UTC()
+	d := boostio.NewDate(t.Date())
+	w.WriteDate(d)
+	w.WriteTimeDuration(boostio.TimeDuration{Duration: t.Sub(d.Time)})
+	return w.err
+}
+
+// WriteTimeDuration writes a boost::posix_time::time_duration, truncated to
+// the microsecond.
+func (w *WBuffer) WriteTimeDuration(v boostio.TimeDuration) error {
+	w.WriteTypeDescr(timeDurationType)
+	w.WriteBool(v.Special != boostio.NotSpecial)
+	if v.Special != boostio.NotSpecial {
+		w.WriteString(v.Special.String())
+		return w.err
+	}
+
+	h, m, s, us := splitDuration(v.Duration)
+	w.WriteI64(h)
+	w.WriteI64(m)
+	w.WriteI64(s)
+	w.WriteI64(us)
 	return w.err
 }
 
@@ -213,6 +703,58 @@ func (w *WBuffer) WriteC128(v complex128) error {
 	return w.err
 }
 
+func (w *WBuffer) sizeofInt() int {
+	if n := w.hdr.sizeofInt(); n != 0 {
+		return n
+	}
+	return 4
+}
+
+func (w *WBuffer) sizeofLong() int {
+	if n := w.hdr.sizeofLong(); n != 0 {
+		return n
+	}
+	switch w.arch {
+	case 32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func (w *WBuffer) sizeofWChar() int {
+	switch {
+	case w.opts.wchar != 0:
+		return w.opts.wchar
+	case w.arch == 64 && w.hdr.sizeofLong() == 4:
+		return 2
+	default:
+		return 4
+	}
+}
+
+func (w *WBuffer) sizeofLongDouble() int {
+	switch {
+	case w.opts.ldbl != 0:
+		return w.opts.ldbl
+	case w.arch == 32:
+		return 12
+	case w.hdr.sizeofLong() == 4:
+		return 8
+	default:
+		return 16
+	}
+}
+
+func (w *WBuffer) sizeofLimb() int {
+	switch w.arch {
+	case 32:
+		return 4
+	default:
+		return 8
+	}
+}
+
 func (w *WBuffer) write(n int) error {
 	if w.err != nil {
 		return w.err
@@ -220,6 +762,7 @@ func (w *WBuffer) write(n int) error {
 
 	var nn int
 	nn, w.err = w.w.Write(w.buf[:n])
+	w.off += int64(nn)
 	if w.err == nil && nn < n {
 		w.err = io.ErrShortWrite
 	}
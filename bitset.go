@@ -0,0 +1,147 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Bitset is a fixed-size sequence of bits, the Go equivalent of a C++
+// std::bitset<N>.
+//
+// Boost serializes a std::bitset<N> as a string of N '0' and '1'
+// characters, the most significant bit first.
+// Different sizes are different C++ classes: a Bitset must thus be
+// created with its final size (see NewBitset) before being decoded.
+type Bitset struct {
+	n      int
+	blocks []uint64
+}
+
+// NewBitset returns a new bitset of n bits, all cleared.
+func NewBitset(n int) *Bitset {
+	return &Bitset{n: n, blocks: make([]uint64, nblocks(n))}
+}
+
+// ParseBitset parses a string of '0' and '1' characters, most significant
+// bit first, as returned by std::bitset::to_string.
+func ParseBitset(s string) (*Bitset, error) {
+	b := NewBitset(len(s))
+	for i, c := range []byte(s) {
+		switch c {
+		case '0':
+		case '1':
+			b.Set(len(s)-1-i, true)
+		default:
+			return nil, fmt.Errorf("boostio: invalid bitset character %q", c)
+		}
+	}
+	return b, nil
+}
+
+func nblocks(n int) int {
+	return (n + 63) / 64
+}
+
+// Len returns the number of bits of the bitset.
+func (b *Bitset) Len() int { return b.n }
+
+// Test reports whether the i-th bit is set.
+func (b *Bitset) Test(i int) bool {
+	b.check(i)
+	return b.blocks[i/64]&(1<<uint(i%64)) != 0
+}
+
+// Set sets the i-th bit to v.
+func (b *Bitset) Set(i int, v bool) {
+	b.check(i)
+	switch v {
+	case true:
+		b.blocks[i/64] |= 1 << uint(i%64)
+	default:
+		b.blocks[i/64] &^= 1 << uint(i%64)
+	}
+}
+
+// Count returns the number of bits set.
+func (b *Bitset) Count() int {
+	n := 0
+	for _, v := range b.blocks {
+		n += bits.OnesCount64(v)
+	}
+	return n
+}
+
+// String returns the bits as a string of '0' and '1' characters, the most
+// significant bit first, like std::bitset::to_string.
+func (b *Bitset) String() string {
+	var o strings.Builder
+	o.Grow(b.n)
+	for i := b.n - 1; i >= 0; i-- {
+		switch b.Test(i) {
+		case true:
+			o.WriteByte('1')
+		default:
+			o.WriteByte('0')
+		}
+	}
+	return o.String()
+}
+
+func (b *Bitset) check(i int) {
+	if i < 0 || i >= b.n {
+		panic(fmt.Errorf("boostio: bit index %d out of range [0, %d)", i, b.n))
+	}
+}
+
+// DynamicBitset is a resizable sequence of bits, the Go equivalent of a
+// C++ boost::dynamic_bitset<>.
+//
+// Boost serializes a boost::dynamic_bitset as its number of bits,
+// followed by the std::vector of blocks holding these bits, the least
+// significant bit first.
+type DynamicBitset struct {
+	Bitset
+}
+
+// NewDynamicBitset returns a new dynamic bitset holding the first n bits
+// of the given blocks.
+// Missing blocks are considered cleared.
+func NewDynamicBitset(n int, blocks ...uint64) *DynamicBitset {
+	b := &DynamicBitset{Bitset: *NewBitset(n)}
+	copy(b.blocks, blocks)
+	b.trim()
+	return b
+}
+
+// Blocks returns the 64-bits blocks holding the bits of the bitset.
+func (b *DynamicBitset) Blocks() []uint64 { return b.blocks }
+
+// Resize changes the number of bits of the bitset to n.
+// New bits are cleared.
+func (b *DynamicBitset) Resize(n int) {
+	nb := nblocks(n)
+	for len(b.blocks) < nb {
+		b.blocks = append(b.blocks, 0)
+	}
+	b.blocks = b.blocks[:nb]
+	b.n = n
+	b.trim()
+}
+
+// PushBack appends a bit to the bitset.
+func (b *DynamicBitset) PushBack(v bool) {
+	b.Resize(b.n + 1)
+	b.Set(b.n-1, v)
+}
+
+// trim clears the unused bits of the last block.
+func (b *DynamicBitset) trim() {
+	if r := b.n % 64; r != 0 {
+		b.blocks[len(b.blocks)-1] &= 1<<uint(r) - 1
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBitset(t *testing.T) {
+	b := NewBitset(70)
+	b.Set(0, true)
+	b.Set(3, true)
+	b.Set(69, true)
+	b.Set(3, false)
+
+	if got, want := b.Count(), 2; got != want {
+		t.Fatalf("invalid count: got=%d, want=%d", got, want)
+	}
+
+	str := b.String()
+	if got, want := str, "1"+strings.Repeat("0", 68)+"1"; got != want {
+		t.Fatalf("invalid string:\ngot= %q\nwant=%q", got, want)
+	}
+
+	v, err := ParseBitset(str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, b) {
+		t.Fatalf("round trip failed:\ngot= %v\nwant=%v", v, b)
+	}
+
+	_, err = ParseBitset("0102")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestDynamicBitset(t *testing.T) {
+	b := NewDynamicBitset(66, 0xffffffffffffffff, 0xff)
+	if got, want := b.Blocks(), []uint64{0xffffffffffffffff, 0x3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid blocks: got=%#x, want=%#x", got, want)
+	}
+
+	b.Resize(4)
+	if got, want := b.String(), "1111"; got != want {
+		t.Fatalf("invalid string: got=%q, want=%q", got, want)
+	}
+
+	b.Resize(65)
+	b.PushBack(true)
+	if got, want := b.Blocks(), []uint64{0xf, 0x2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid blocks: got=%#x, want=%#x", got, want)
+	}
+	if got, want := b.Len(), 66; got != want {
+		t.Fatalf("invalid length: got=%d, want=%d", got, want)
+	}
+}
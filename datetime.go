@@ -0,0 +1,125 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"fmt"
+	"time"
+)
+
+// Special is one of the special values of the Boost.DateTime types.
+type Special int8
+
+const (
+	NotSpecial   Special = iota // regular value
+	NotADateTime                // boost::date_time::not_a_date_time
+	PosInfinity                 // boost::date_time::pos_infin
+	NegInfinity                 // boost::date_time::neg_infin
+)
+
+// ParseSpecial parses the name of a special value, as written by Boost.
+// ParseSpecial returns NotSpecial for any other string.
+func ParseSpecial(s string) Special {
+	switch s {
+	case "not-a-date-time":
+		return NotADateTime
+	case "+infinity":
+		return PosInfinity
+	case "-infinity":
+		return NegInfinity
+	}
+	return NotSpecial
+}
+
+// String returns the name of the special value, as written by Boost.
+func (s Special) String() string {
+	switch s {
+	case NotADateTime:
+		return "not-a-date-time"
+	case PosInfinity:
+		return "+infinity"
+	case NegInfinity:
+		return "-infinity"
+	}
+	return ""
+}
+
+// Date is the Go equivalent of a C++ boost::gregorian::date.
+//
+// Boost serializes a date as its ISO representation (e.g. "20020131") or
+// as the name of its special value.
+type Date struct {
+	time.Time // midnight UTC of the day, zero for special values.
+
+	Special Special
+}
+
+// NewDate returns the date of the given day.
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{Time: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// ParseDate parses a date written in the ISO format (e.g. "20020131"), or
+// the name of a special value.
+func ParseDate(s string) (Date, error) {
+	if v := ParseSpecial(s); v != NotSpecial {
+		return Date{Special: v}, nil
+	}
+	t, err := time.Parse("20060102", s)
+	if err != nil {
+		return Date{}, fmt.Errorf("boostio: invalid date %q", s)
+	}
+	return Date{Time: t}, nil
+}
+
+// String returns the ISO representation of the date, or the name of its
+// special value.
+func (d Date) String() string {
+	if d.Special != NotSpecial {
+		return d.Special.String()
+	}
+	return d.Time.Format("20060102")
+}
+
+// PTime is the Go equivalent of a C++ boost::posix_time::ptime.
+//
+// Boost serializes a ptime as its date, followed by its time of day when
+// the date is not a special value.
+// A ptime has no time zone: PTime values are expressed in UTC, with a
+// microsecond resolution.
+type PTime struct {
+	time.Time // zero for special values.
+
+	Special Special
+}
+
+// String returns the ISO representation of the time, or the name of its
+// special value.
+func (t PTime) String() string {
+	if t.Special != NotSpecial {
+		return t.Special.String()
+	}
+	return t.Time.UTC().Format("20060102T150405.999999")
+}
+
+// TimeDuration is the Go equivalent of a C++
+// boost::posix_time::time_duration.
+//
+// Boost serializes a time_duration as a special value flag, followed by
+// the name of its special value or its hours, minutes, seconds and
+// fractional seconds (in microseconds).
+type TimeDuration struct {
+	time.Duration // zero for special values.
+
+	Special Special
+}
+
+// String returns the duration, or the name of its special value.
+func (d TimeDuration) String() string {
+	if d.Special != NotSpecial {
+		return d.Special.String()
+	}
+	return d.Duration.String()
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDate(t *testing.T) {
+	for _, tc := range []struct {
+		str  string
+		want Date
+	}{
+		{"20020131", NewDate(2002, time.January, 31)},
+		{"not-a-date-time", Date{Special: NotADateTime}},
+		{"+infinity", Date{Special: PosInfinity}},
+		{"-infinity", Date{Special: NegInfinity}},
+	} {
+		got, err := ParseDate(tc.str)
+		if err != nil {
+			t.Errorf("%q: %v", tc.str, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got=%v, want=%v", tc.str, got, tc.want)
+		}
+		if got, want := got.String(), tc.str; got != want {
+			t.Errorf("invalid string: got=%q, want=%q", got, want)
+		}
+	}
+
+	_, err := ParseDate("2002-01-31")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestPTime(t *testing.T) {
+	v := PTime{Time: time.Date(2018, time.March, 4, 10, 20, 30, 123456000, time.UTC)}
+	if got, want := v.String(), "20180304T102030.123456"; got != want {
+		t.Fatalf("got=%q, want=%q", got, want)
+	}
+	v = PTime{Special: NegInfinity}
+	if got, want := v.String(), "-infinity"; got != want {
+		t.Fatalf("got=%q, want=%q", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DecFloat is the serialized form of a C++
+// boost::multiprecision::cpp_dec_float<Digits10>.
+//
+// A cpp_dec_float holds its value as a fixed number of base 10^8 digits,
+// the most significant first, and a decimal exponent, multiple of 8, for
+// its most significant digit.
+type DecFloat struct {
+	Data  []uint32 // base 10^8 digits.
+	Exp   int32    // decimal exponent of the first digit.
+	Neg   bool     // whether the value is negative.
+	Class int32    // finite, infinite or NaN.
+	Prec  int32    // number of digits in use.
+}
+
+// Classes of DecFloat values.
+const (
+	DecFloatFinite = 0
+	DecFloatInf    = 1
+	DecFloatNaN    = 2
+)
+
+const decFloatElemDigits = 8
+
+// DecFloatLen returns the number of base 10^8 digits of a
+// cpp_dec_float<digits10>.
+func DecFloatLen(digits10 int) int {
+	if digits10 < 9 {
+		digits10 = 9
+	}
+	n := (digits10 + decFloatElemDigits - 1) / decFloatElemDigits
+	if n < 2 {
+		n = 2
+	}
+	return n + 3 // guard digits.
+}
+
+// NewDecFloat returns the cpp_dec_float<digits10> value nearest to f.
+func NewDecFloat(f *big.Float, digits10 int) DecFloat {
+	n := DecFloatLen(digits10)
+	v := DecFloat{
+		Data:  make([]uint32, n),
+		Neg:   f.Signbit(),
+		Class: DecFloatFinite,
+		Prec:  int32(n),
+	}
+	switch {
+	case f.IsInf():
+		v.Class = DecFloatInf
+		return v
+	case f.Sign() == 0:
+		return v
+	}
+
+	// d.ddd...e±x, with all the digits the cpp_dec_float can hold.
+	txt := new(big.Float).Abs(f).Text('e', n*decFloatElemDigits-1)
+	i := strings.IndexByte(txt, 'e')
+	x, _ := strconv.Atoi(txt[i+1:])
+	digits := txt[:1] + txt[2:i]
+
+	lead := x % decFloatElemDigits
+	if lead < 0 {
+		lead += decFloatElemDigits
+	}
+	lead++
+
+	v.Exp = int32(x - lead + 1)
+	for j := range v.Data {
+		var d string
+		switch j {
+		case 0:
+			d, digits = digits[:lead], digits[lead:]
+		default:
+			d, digits = digits[:decFloatElemDigits], digits[decFloatElemDigits:]
+		}
+		u, _ := strconv.ParseUint(d, 10, 32)
+		v.Data[j] = uint32(u)
+	}
+	return v
+}
+
+// BigFloat returns the value of the cpp_dec_float, rounded to a binary
+// precision equivalent to its number of decimal digits.
+func (v DecFloat) BigFloat() (*big.Float, error) {
+	prec := uint(math.Ceil(float64(len(v.Data)*decFloatElemDigits) * math.Log2(10)))
+	switch v.Class {
+	case DecFloatFinite:
+		// ok.
+	case DecFloatInf:
+		return new(big.Float).SetPrec(prec).SetInf(v.Neg), nil
+	case DecFloatNaN:
+		return nil, errors.New("boostio: NaN cpp_dec_float can not be represented as a big.Float")
+	default:
+		return nil, fmt.Errorf("boostio: invalid cpp_dec_float class %d", v.Class)
+	}
+
+	var o strings.Builder
+	if v.Neg {
+		o.WriteByte('-')
+	}
+	o.WriteString("0")
+	for _, d := range v.Data {
+		if d >= 1e8 {
+			return nil, fmt.Errorf("boostio: invalid cpp_dec_float digit %d", d)
+		}
+		fmt.Fprintf(&o, "%0*d", decFloatElemDigits, d)
+	}
+	exp := int64(v.Exp) - int64(len(v.Data)-1)*decFloatElemDigits
+	fmt.Fprintf(&o, "e%d", exp)
+
+	f, _, err := big.ParseFloat(o.String(), 10, prec, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("boostio: invalid cpp_dec_float: %w", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestDecFloatLen(t *testing.T) {
+	for _, tc := range []struct {
+		digits10 int
+		want     int
+	}{
+		{1, 5},
+		{16, 5},
+		{17, 6},
+		{50, 10},
+		{100, 16},
+	} {
+		if got := DecFloatLen(tc.digits10); got != tc.want {
+			t.Errorf("digits10=%d: got=%d, want=%d", tc.digits10, got, tc.want)
+		}
+	}
+}
+
+func TestDecFloat(t *testing.T) {
+	for _, tc := range []struct {
+		v    float64
+		want DecFloat
+	}{
+		{
+			v:    0,
+			want: DecFloat{Data: make([]uint32, 5), Prec: 5},
+		},
+		{
+			v:    1.5,
+			want: DecFloat{Data: []uint32{1, 50000000, 0, 0, 0}, Prec: 5},
+		},
+		{
+			v:    -123456789.25,
+			want: DecFloat{Data: []uint32{1, 23456789, 25000000, 0, 0}, Exp: 8, Neg: true, Prec: 5},
+		},
+		{
+			v:    0.000244140625,
+			want: DecFloat{Data: []uint32{24414, 6250000, 0, 0, 0}, Exp: -8, Prec: 5},
+		},
+		{
+			v:    math.Inf(-1),
+			want: DecFloat{Data: make([]uint32, 5), Neg: true, Class: DecFloatInf, Prec: 5},
+		},
+	} {
+		got := NewDecFloat(big.NewFloat(tc.v), 16)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: invalid cpp_dec_float:\ngot= %+v\nwant=%+v", tc.v, got, tc.want)
+			continue
+		}
+
+		f, err := got.BigFloat()
+		if err != nil {
+			t.Errorf("%v: %v", tc.v, err)
+			continue
+		}
+		if v, _ := f.Float64(); v != tc.v {
+			t.Errorf("%v: round trip failed: got=%v", tc.v, v)
+		}
+	}
+
+	_, err := DecFloat{Data: make([]uint32, 5), Class: DecFloatNaN}.BigFloat()
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+// Enum is the interface implemented by Go named integer types holding the
+// values of a C++ enum or enum class.
+//
+// Boost serializes the values of an enum as a C++ int, whatever its
+// underlying type: values of an Enum type are read and written with the
+// size of an int recorded in the archive, instead of the size of their Go
+// type.
+type Enum interface {
+	// EnumValues returns the allowed values of the enum, or nil if all
+	// values are allowed.
+	EnumValues() []int64
+}
+
+// ValidEnum reports whether v is one of the allowed values of the enum e.
+func ValidEnum(e Enum, v int64) bool {
+	vs := e.EnumValues()
+	if vs == nil {
+		return true
+	}
+	for _, x := range vs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
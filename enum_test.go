@@ -0,0 +1,33 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import "testing"
+
+type color uint8
+
+func (color) EnumValues() []int64 { return []int64{0, 1, 4} }
+
+type level int32
+
+func (level) EnumValues() []int64 { return nil }
+
+func TestValidEnum(t *testing.T) {
+	for _, tc := range []struct {
+		e    Enum
+		v    int64
+		want bool
+	}{
+		{color(0), 0, true},
+		{color(0), 4, true},
+		{color(0), 2, false},
+		{color(0), -1, false},
+		{level(0), -42, true},
+	} {
+		if got := ValidEnum(tc.e, tc.v); got != tc.want {
+			t.Errorf("%T(%d): got=%v, want=%v", tc.e, tc.v, got, tc.want)
+		}
+	}
+}
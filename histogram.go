@@ -0,0 +1,117 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+// AxisKind identifies the C++ type of a Boost.Histogram axis.
+type AxisKind int8
+
+const (
+	RegularAxisKind     AxisKind = iota // boost::histogram::axis::regular<>
+	VariableAxisKind                    // boost::histogram::axis::variable<>
+	IntegerAxisKind                     // boost::histogram::axis::integer<>
+	CategoryAxisKind                    // boost::histogram::axis::category<int>
+	StrCategoryAxisKind                 // boost::histogram::axis::category<std::string>
+)
+
+// Axis is a Boost.Histogram axis.
+type Axis interface {
+	Kind() AxisKind
+}
+
+// RegularAxis is a boost::histogram::axis::regular<> axis, with Size bins
+// of equal width Delta, starting at Min.
+type RegularAxis struct {
+	Size  int32
+	Meta  string
+	Min   float64
+	Delta float64
+}
+
+// VariableAxis is a boost::histogram::axis::variable<> axis, with bins of
+// varying widths.
+type VariableAxis struct {
+	Edges []float64
+	Meta  string
+}
+
+// IntegerAxis is a boost::histogram::axis::integer<> axis, with Size bins
+// of width 1, starting at Min.
+type IntegerAxis struct {
+	Size int32
+	Meta string
+	Min  int32
+}
+
+// CategoryAxis is a boost::histogram::axis::category<int> axis.
+type CategoryAxis struct {
+	Values []int32
+	Meta   string
+}
+
+// StrCategoryAxis is a boost::histogram::axis::category<std::string> axis.
+type StrCategoryAxis struct {
+	Values []string
+	Meta   string
+}
+
+func (RegularAxis) Kind() AxisKind     { return RegularAxisKind }
+func (VariableAxis) Kind() AxisKind    { return VariableAxisKind }
+func (IntegerAxis) Kind() AxisKind     { return IntegerAxisKind }
+func (CategoryAxis) Kind() AxisKind    { return CategoryAxisKind }
+func (StrCategoryAxis) Kind() AxisKind { return StrCategoryAxisKind }
+
+// WeightedSum is a boost::histogram::accumulators::weighted_sum<double>.
+type WeightedSum struct {
+	SumOfWeights        float64
+	SumOfWeightsSquared float64
+}
+
+// DefaultAxisVariant lists the alternatives of the axis::variant of a
+// Histogram whose Variant is nil.
+var DefaultAxisVariant = []AxisKind{
+	RegularAxisKind,
+	VariableAxisKind,
+	IntegerAxisKind,
+	CategoryAxisKind,
+	StrCategoryAxisKind,
+}
+
+// Histogram is the Go equivalent of a C++ boost::histogram::histogram,
+// with a std::vector<axis::variant<...>> of axes and a dense int storage
+// or, if Weighted is true, a dense weight storage.
+//
+// Boost serializes a histogram as its axes, each preceded by the index of
+// its type among the alternatives of the axis::variant, followed by its
+// storage. Neither the alternatives of the variant nor the type of the
+// storage are recorded in the archive: Variant and Weighted must thus be
+// set before a Histogram is decoded.
+// Bins include the underflow and overflow bins of the axes, if any.
+type Histogram struct {
+	Variant  []AxisKind // alternatives of the axis::variant (default: DefaultAxisVariant).
+	Axes     []Axis
+	Weighted bool          // whether the storage is a weight_storage.
+	Counts   []int32       // bins of an int storage.
+	Weights  []WeightedSum // bins of a weight storage.
+}
+
+// AxisVariant returns the alternatives of the axis::variant of the
+// histogram.
+func (h *Histogram) AxisVariant() []AxisKind {
+	if h.Variant == nil {
+		return DefaultAxisVariant
+	}
+	return h.Variant
+}
+
+// Which returns the index of the given axis among the alternatives of the
+// axis::variant of the histogram, or -1.
+func (h *Histogram) Which(axis Axis) int {
+	for i, k := range h.AxisVariant() {
+		if k == axis.Kind() {
+			return i
+		}
+	}
+	return -1
+}
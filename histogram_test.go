@@ -0,0 +1,28 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import "testing"
+
+func TestHistogramWhich(t *testing.T) {
+	var h Histogram
+	if got, want := h.Which(IntegerAxis{}), 2; got != want {
+		t.Fatalf("got=%d, want=%d", got, want)
+	}
+
+	h.Variant = []AxisKind{StrCategoryAxisKind, RegularAxisKind}
+	for _, tc := range []struct {
+		axis Axis
+		want int
+	}{
+		{RegularAxis{}, 1},
+		{StrCategoryAxis{}, 0},
+		{VariableAxis{}, -1},
+	} {
+		if got := h.Which(tc.axis); got != tc.want {
+			t.Errorf("%T: got=%d, want=%d", tc.axis, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// LongDouble holds a C++ long double value, in the x87 80-bits extended
+// precision format.
+//
+// Archives written on x86-64 Linux hold such values as 16 bytes (10 bytes
+// of data followed by padding), on x86 Linux as 12 bytes.
+// Other platforms (e.g. Windows) use a plain 8 bytes double.
+type LongDouble struct {
+	SignExp  uint16 // sign bit and 15-bits biased exponent.
+	Mantissa uint64 // 64-bits significand, with an explicit integer bit.
+}
+
+const (
+	ldBias    = 16383
+	ldExpMask = 0x7fff
+	ldPrec    = 64
+)
+
+// NewLongDouble returns the long double value of v.
+// The conversion is exact.
+func NewLongDouble(v float64) LongDouble {
+	var (
+		raw  = math.Float64bits(v)
+		sign = uint16(raw>>63) << 15
+		exp  = int(raw>>52) & 0x7ff
+		frac = raw & (1<<52 - 1)
+	)
+	switch {
+	case exp == 0x7ff:
+		// infinities and NaNs.
+		return LongDouble{SignExp: sign | ldExpMask, Mantissa: 1<<63 | frac<<11}
+	case exp == 0 && frac == 0:
+		return LongDouble{SignExp: sign}
+	case exp == 0:
+		// subnormal float64 values are normal long double values.
+		shift := bits.LeadingZeros64(frac)
+		return LongDouble{
+			SignExp:  sign | uint16(ldBias-1023-52+63-shift+1),
+			Mantissa: frac << uint(shift),
+		}
+	default:
+		return LongDouble{
+			SignExp:  sign | uint16(exp-1023+ldBias),
+			Mantissa: 1<<63 | frac<<11,
+		}
+	}
+}
+
+// NewLongDoubleFromBig returns the long double value nearest to f.
+func NewLongDoubleFromBig(f *big.Float) LongDouble {
+	var sign uint16
+	if f.Signbit() {
+		sign = 1 << 15
+	}
+	switch {
+	case f.IsInf():
+		return LongDouble{SignExp: sign | ldExpMask, Mantissa: 1 << 63}
+	case f.Sign() == 0:
+		return LongDouble{SignExp: sign}
+	}
+
+	v := new(big.Float).SetPrec(ldPrec).SetMode(big.ToNearestEven).Set(f)
+	v.Abs(v)
+	exp := v.MantExp(nil) - 1 + ldBias
+	switch {
+	case exp >= ldExpMask:
+		return LongDouble{SignExp: sign | ldExpMask, Mantissa: 1 << 63}
+	case exp <= 0:
+		// subnormal values.
+		v.SetMantExp(v, ldBias-1+ldPrec-1)
+		mant, _ := v.Uint64()
+		return LongDouble{SignExp: sign, Mantissa: mant}
+	}
+	v.SetMantExp(v, ldPrec-(exp-ldBias+1))
+	mant, _ := v.Uint64()
+	return LongDouble{SignExp: sign | uint16(exp), Mantissa: mant}
+}
+
+// IsNaN reports whether ld is a "not-a-number" value.
+func (ld LongDouble) IsNaN() bool {
+	return ld.SignExp&ldExpMask == ldExpMask && ld.Mantissa<<1 != 0
+}
+
+// Float64 returns the float64 value nearest to ld.
+func (ld LongDouble) Float64() float64 {
+	if ld.IsNaN() {
+		return math.NaN()
+	}
+	v, _ := ld.BigFloat().Float64()
+	return v
+}
+
+// BigFloat returns the exact value of ld, with a 64-bits precision.
+//
+// BigFloat panics with a big.ErrNaN if ld is a "not-a-number" value.
+func (ld LongDouble) BigFloat() *big.Float {
+	if ld.IsNaN() {
+		panic(big.ErrNaN{})
+	}
+
+	var (
+		neg = ld.SignExp>>15 != 0
+		exp = int(ld.SignExp & ldExpMask)
+		v   = new(big.Float).SetPrec(ldPrec)
+	)
+	switch exp {
+	case ldExpMask:
+		v.SetInf(neg)
+		return v
+	case 0:
+		exp = 1 // subnormal values.
+	}
+	v.SetUint64(ld.Mantissa)
+	v.SetMantExp(v, exp-ldBias-(ldPrec-1))
+	if neg {
+		v.Neg(v)
+	}
+	return v
+}
+
+// String returns the decimal representation of ld.
+func (ld LongDouble) String() string {
+	if ld.IsNaN() {
+		return "NaN"
+	}
+	return ld.BigFloat().Text('g', 21)
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestLongDouble(t *testing.T) {
+	for _, tc := range []struct {
+		v    float64
+		want LongDouble
+	}{
+		{0, LongDouble{}},
+		{math.Copysign(0, -1), LongDouble{SignExp: 0x8000}},
+		{1, LongDouble{SignExp: 0x3fff, Mantissa: 0x8000000000000000}},
+		{-2.5, LongDouble{SignExp: 0xc000, Mantissa: 0xa000000000000000}},
+		{math.Pi, LongDouble{SignExp: 0x4000, Mantissa: 0xc90fdaa22168c000}},
+		{math.MaxFloat64, LongDouble{SignExp: 0x43fe, Mantissa: 0xfffffffffffff800}},
+		{math.SmallestNonzeroFloat64, LongDouble{SignExp: 0x3bcd, Mantissa: 0x8000000000000000}},
+		{math.Inf(+1), LongDouble{SignExp: 0x7fff, Mantissa: 0x8000000000000000}},
+		{math.Inf(-1), LongDouble{SignExp: 0xffff, Mantissa: 0x8000000000000000}},
+	} {
+		t.Run(big.NewFloat(tc.v).String(), func(t *testing.T) {
+			got := NewLongDouble(tc.v)
+			if got != tc.want {
+				t.Fatalf("invalid long double: got=%#v, want=%#v", got, tc.want)
+			}
+			if got, want := got.Float64(), tc.v; got != want || math.Signbit(got) != math.Signbit(want) {
+				t.Fatalf("invalid float64: got=%v, want=%v", got, want)
+			}
+			if got, want := NewLongDoubleFromBig(got.BigFloat()), tc.want; got != want {
+				t.Fatalf("invalid big.Float round trip: got=%#v, want=%#v", got, want)
+			}
+		})
+	}
+}
+
+func TestLongDoubleBig(t *testing.T) {
+	pi, _, err := big.ParseFloat("3.14159265358979323846264338327950288", 10, 200, big.ToNearestEven)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ld := NewLongDoubleFromBig(pi)
+	if got, want := ld, (LongDouble{SignExp: 0x4000, Mantissa: 0xc90fdaa22168c235}); got != want {
+		t.Fatalf("invalid long double: got=%#v, want=%#v", got, want)
+	}
+	if got, want := ld.String(), "3.14159265358979323851"; got != want {
+		t.Fatalf("invalid string: got=%q, want=%q", got, want)
+	}
+
+	// 1 + 2^-63 can not be represented as a float64.
+	v := new(big.Float).SetPrec(64).SetInt64(1)
+	v.Add(v, new(big.Float).SetMantExp(big.NewFloat(1), -63))
+	ld = NewLongDoubleFromBig(v)
+	if got, want := ld, (LongDouble{SignExp: 0x3fff, Mantissa: 0x8000000000000001}); got != want {
+		t.Fatalf("invalid long double: got=%#v, want=%#v", got, want)
+	}
+	if got := ld.BigFloat(); got.Cmp(v) != 0 {
+		t.Fatalf("invalid big.Float: got=%v, want=%v", got, v)
+	}
+	if got, want := ld.Float64(), 1.0; got != want {
+		t.Fatalf("invalid float64: got=%v, want=%v", got, want)
+	}
+
+	// subnormal long double.
+	sub := LongDouble{Mantissa: 0x1234}
+	if got, want := NewLongDoubleFromBig(sub.BigFloat()), sub; got != want {
+		t.Fatalf("invalid subnormal round trip: got=%#v, want=%#v", got, want)
+	}
+
+	// overflow.
+	huge := new(big.Float).SetMantExp(big.NewFloat(1), 20000)
+	if got, want := NewLongDoubleFromBig(huge), NewLongDouble(math.Inf(+1)); got != want {
+		t.Fatalf("invalid overflow: got=%#v, want=%#v", got, want)
+	}
+}
+
+func TestLongDoubleNaN(t *testing.T) {
+	ld := NewLongDouble(math.NaN())
+	if !ld.IsNaN() {
+		t.Fatalf("expected a NaN: %#v", ld)
+	}
+	if got := ld.Float64(); !math.IsNaN(got) {
+		t.Fatalf("expected a NaN: %v", got)
+	}
+	if got, want := ld.String(), "NaN"; got != want {
+		t.Fatalf("invalid string: got=%q, want=%q", got, want)
+	}
+
+	defer func() {
+		e := recover()
+		if _, ok := e.(big.ErrNaN); !ok {
+			t.Fatalf("expected a big.ErrNaN panic, got %#v", e)
+		}
+	}()
+	_ = ld.BigFloat()
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import "fmt"
+
+// IndexKind identifies the type of an index of a C++
+// boost::multi_index_container.
+//
+// Boost serializes a multi_index_container as its number of elements,
+// followed by its elements, in the order of its first index, and by the
+// data some indices need to restore their own order.
+// The layout of this data depends on the types of all the indices of
+// the container, which are not recorded in the archive.
+type IndexKind int8
+
+const (
+	OrderedUnique    IndexKind = iota // boost::multi_index::ordered_unique
+	OrderedNonUnique                  // boost::multi_index::ordered_non_unique
+	HashedUnique                      // boost::multi_index::hashed_unique
+	HashedNonUnique                   // boost::multi_index::hashed_non_unique
+	Sequenced                         // boost::multi_index::sequenced
+	RandomAccess                      // boost::multi_index::random_access
+)
+
+var indexKindNames = [...]string{
+	OrderedUnique:    "ordered_unique",
+	OrderedNonUnique: "ordered_non_unique",
+	HashedUnique:     "hashed_unique",
+	HashedNonUnique:  "hashed_non_unique",
+	Sequenced:        "sequenced",
+	RandomAccess:     "random_access",
+}
+
+// ParseIndexKind parses the C++ name of an index type (e.g. "sequenced").
+func ParseIndexKind(s string) (IndexKind, error) {
+	for i, name := range indexKindNames {
+		if name == s {
+			return IndexKind(i), nil
+		}
+	}
+	return 0, fmt.Errorf("boostio: invalid multi_index_container index type %q", s)
+}
+
+// String returns the C++ name of the index type.
+func (k IndexKind) String() string {
+	if k < 0 || int(k) >= len(indexKindNames) {
+		return fmt.Sprintf("IndexKind(%d)", int(k))
+	}
+	return indexKindNames[k]
+}
+
+// Hashed reports whether the index is a hashed index.
+func (k IndexKind) Hashed() bool {
+	return k == HashedUnique || k == HashedNonUnique
+}
+
+// Positional reports whether the index records the order of its elements
+// in archives.
+func (k IndexKind) Positional() bool {
+	switch k {
+	case OrderedNonUnique, HashedNonUnique, Sequenced, RandomAccess:
+		return true
+	}
+	return false
+}
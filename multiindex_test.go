@@ -0,0 +1,28 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import "testing"
+
+func TestIndexKind(t *testing.T) {
+	for _, k := range []IndexKind{
+		OrderedUnique, OrderedNonUnique,
+		HashedUnique, HashedNonUnique,
+		Sequenced, RandomAccess,
+	} {
+		v, err := ParseIndexKind(k.String())
+		if err != nil {
+			t.Fatalf("%v: %v", k, err)
+		}
+		if v != k {
+			t.Fatalf("got=%v, want=%v", v, k)
+		}
+	}
+
+	_, err := ParseIndexKind("ranked_unique")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
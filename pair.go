@@ -0,0 +1,23 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+// Pair is the Go equivalent of a C++ std::pair<A,B>.
+//
+// Like any other C++ class, a std::pair is serialized with its class
+// information, followed by its first and second members.
+//
+// A C++ std::tuple is serialized in the same way, with its elements
+// written in order: it can be described with a plain Go struct whose
+// fields are the elements of the tuple.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// MakePair creates a new pair from the given values.
+func MakePair[A, B any](a A, b B) Pair[A, B] {
+	return Pair[A, B]{First: a, Second: b}
+}
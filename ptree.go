@@ -0,0 +1,84 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import "strings"
+
+// PTree is the Go equivalent of a C++ boost::property_tree::ptree.
+//
+// A property tree holds a data string and an ordered list of (key, child)
+// pairs. Keys need not be unique.
+//
+// Boost serializes a ptree as its number of children, followed, if there
+// are any, by the item version and the (key, child) pairs, and finally by
+// its data string.
+type PTree struct {
+	Data     string
+	Children []Pair[string, PTree]
+}
+
+// Len returns the number of direct children of the tree.
+func (t *PTree) Len() int { return len(t.Children) }
+
+// Add appends a new child with the given key to the tree, and returns it.
+func (t *PTree) Add(key string, child PTree) *PTree {
+	t.Children = append(t.Children, MakePair(key, child))
+	return &t.Children[len(t.Children)-1].Second
+}
+
+// Child returns the first direct child with the given key.
+func (t *PTree) Child(key string) (*PTree, bool) {
+	for i := range t.Children {
+		if t.Children[i].First == key {
+			return &t.Children[i].Second, true
+		}
+	}
+	return nil, false
+}
+
+// Get returns the node at the given path.
+//
+// A path is a list of keys separated by dots (e.g. "a.b.c"). At each
+// level, the first child with a matching key is selected.
+func (t *PTree) Get(path string) (*PTree, bool) {
+	node := t
+	if path == "" {
+		return node, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		child, ok := node.Child(key)
+		if !ok {
+			return nil, false
+		}
+		node = child
+	}
+	return node, true
+}
+
+// Value returns the data of the node at the given path.
+func (t *PTree) Value(path string) (string, bool) {
+	node, ok := t.Get(path)
+	if !ok {
+		return "", false
+	}
+	return node.Data, true
+}
+
+// Put sets the data of the node at the given path, creating the missing
+// nodes along the way, and returns that node.
+func (t *PTree) Put(path, value string) *PTree {
+	node := t
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			child, ok := node.Child(key)
+			if !ok {
+				child = node.Add(key, PTree{})
+			}
+			node = child
+		}
+	}
+	node.Data = value
+	return node
+}
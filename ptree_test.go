@@ -0,0 +1,41 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import "testing"
+
+func TestPTree(t *testing.T) {
+	var tree PTree
+	tree.Put("server.host", "localhost")
+	tree.Put("server.port", "8080")
+	tree.Add("user", PTree{Data: "alice"})
+	tree.Add("user", PTree{Data: "bob"})
+	tree.Put("server.port", "8081")
+
+	if got, want := tree.Len(), 3; got != want {
+		t.Fatalf("invalid number of children: got=%d, want=%d", got, want)
+	}
+
+	for _, tc := range []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"server.host", "localhost", true},
+		{"server.port", "8081", true},
+		{"user", "alice", true},
+		{"server.user", "", false},
+		{"", "", true},
+	} {
+		got, ok := tree.Value(tc.path)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%q: got=(%q, %v), want=(%q, %v)", tc.path, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	if got, want := tree.Children[2].Second.Data, "bob"; got != want {
+		t.Fatalf("got=%q, want=%q", got, want)
+	}
+}
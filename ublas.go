@@ -0,0 +1,61 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import "fmt"
+
+// Vector is the Go equivalent of a dense C++
+// boost::numeric::ublas::vector<double>.
+//
+// Boost serializes a ublas vector as its storage: an unbounded_array
+// holding the number of elements, followed by the elements.
+type Vector []float64
+
+// Matrix is the Go equivalent of a dense C++
+// boost::numeric::ublas::matrix<double, Layout>.
+//
+// Boost serializes a ublas matrix as its number of rows and columns,
+// followed by its storage: an unbounded_array holding the number of
+// elements, followed by the elements in row-major or column-major order.
+// Both layouts are different C++ classes: the layout of a Matrix must
+// thus be set before it is decoded.
+type Matrix struct {
+	Rows     int
+	Cols     int
+	ColMajor bool      // whether Data is stored in column-major order.
+	Data     []float64 // elements of the matrix.
+}
+
+// NewMatrix returns a new rows x cols row-major matrix.
+// If data is nil, a new zero-filled storage is allocated.
+func NewMatrix(rows, cols int, data []float64) *Matrix {
+	if data == nil {
+		data = make([]float64, rows*cols)
+	}
+	if len(data) != rows*cols {
+		panic(fmt.Errorf("boostio: invalid matrix storage size %d (want %d)", len(data), rows*cols))
+	}
+	return &Matrix{Rows: rows, Cols: cols, Data: data}
+}
+
+// At returns the element at row i and column j.
+func (m *Matrix) At(i, j int) float64 {
+	return m.Data[m.index(i, j)]
+}
+
+// Set sets the element at row i and column j to v.
+func (m *Matrix) Set(i, j int, v float64) {
+	m.Data[m.index(i, j)] = v
+}
+
+func (m *Matrix) index(i, j int) int {
+	if i < 0 || i >= m.Rows || j < 0 || j >= m.Cols {
+		panic(fmt.Errorf("boostio: matrix index (%d, %d) out of range [%d, %d)", i, j, m.Rows, m.Cols))
+	}
+	if m.ColMajor {
+		return j*m.Rows + i
+	}
+	return i*m.Cols + j
+}
@@ -0,0 +1,26 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatrix(t *testing.T) {
+	m := NewMatrix(2, 3, []float64{1, 2, 3, 4, 5, 6})
+	if got, want := m.At(1, 0), 4.0; got != want {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+
+	m.ColMajor = true
+	if got, want := m.At(1, 0), 2.0; got != want {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+	m.Set(0, 2, -1)
+	if got, want := m.Data, []float64{1, 2, 3, 4, -1, 6}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%v, want=%v", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// UUID is the Go equivalent of a C++ boost::uuids::uuid.
+//
+// Boost serializes a uuid as a primitive type: its 16 raw bytes in binary
+// archives, its canonical string form in text and XML ones.
+type UUID [16]byte
+
+// ParseUUID parses a UUID in its canonical form
+// (e.g. "01234567-89ab-cdef-0123-456789abcdef").
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("boostio: invalid UUID %q", s)
+	}
+	raw := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(raw)); err != nil {
+		return u, fmt.Errorf("boostio: invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+// String returns the canonical form of the UUID.
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
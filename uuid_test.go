@@ -0,0 +1,38 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+import "testing"
+
+func TestUUID(t *testing.T) {
+	const str = "01234567-89ab-cdef-0123-456789abcdef"
+	want := UUID{
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+	}
+
+	u, err := ParseUUID(str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != want {
+		t.Fatalf("got=%x, want=%x", u, want)
+	}
+	if got := u.String(); got != str {
+		t.Fatalf("got=%q, want=%q", got, str)
+	}
+
+	for _, s := range []string{
+		"",
+		"0123456789abcdef0123456789abcdef",
+		"01234567-89ab-cdef-0123-456789abcdeg",
+		"01234567+89ab-cdef-0123-456789abcdef",
+	} {
+		_, err := ParseUUID(s)
+		if err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2018 The go-boostio Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boostio
+
+// WString is a UTF-8 encoded Go string standing for a C++ std::wstring.
+//
+// Boost archives hold the wchar_t characters of a std::wstring: UTF-32
+// code points when wchar_t is 4 bytes wide (Linux, macOS), UTF-16 code
+// units when it is 2 bytes wide (Windows).
+type WString string
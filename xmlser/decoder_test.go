@@ -5,10 +5,21 @@
 package xmlser_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-boostio/boostio"
 	"github.com/go-boostio/boostio/xmlser"
 )
 
@@ -20,7 +31,7 @@ func TestDecoder(t *testing.T) {
 	defer f.Close()
 
 	dec := xmlser.NewDecoder(f)
-	for _, tc := range typeTestCases[:14] { // FIXME(sbinet): test all test-cases
+	for _, tc := range typeTestCases {
 		t.Run(tc.name, func(t *testing.T) {
 			rv := reflect.New(reflect.TypeOf(tc.want)).Elem()
 			if rv.Kind() == reflect.Map {
@@ -36,3 +47,1714 @@ func TestDecoder(t *testing.T) {
 		})
 	}
 }
+
+// archive prepends a Boost XML archive header to raw.
+func archive(raw string) string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes" ?>
+<!DOCTYPE boost_serialization>
+<boost_serialization signature="serialization::archive" version="17">
+` + raw + `</boost_serialization>
+`
+}
+
+// archiveTestCases holds hand-written XML archives, as written by C++.
+var archiveTestCases = []struct {
+	name string
+	raw  string
+	want []interface{}
+}{
+	{
+		name: "pair",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<count>2</count>
+	<item_version>0</item_version>
+	<item class_id="1" tracking_level="0" version="0">
+		<first>1</first>
+		<second>a</second>
+	</item>
+	<item>
+		<first>2</first>
+		<second>bc</second>
+	</item>
+</v1>
+<v2 class_id="2" tracking_level="0" version="0">
+	<count>1</count>
+	<item_version>0</item_version>
+	<item class_id="3" tracking_level="0" version="0">
+		<first>3</first>
+		<second>x</second>
+	</item>
+</v2>
+<v3>
+	<count>1</count>
+	<item_version>0</item_version>
+	<item>
+		<first>4</first>
+		<second>y</second>
+	</item>
+</v3>
+`,
+		want: []interface{}{
+			[]boostio.Pair[int32, string]{{First: 1, Second: "a"}, {First: 2, Second: "bc"}},
+			map[int32]string{3: "x"},
+			map[int32]string{4: "y"},
+		},
+	},
+	{
+		name: "bitset",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<bits>1000000101</bits>
+</v1>
+<v2 class_id="1" tracking_level="0" version="0">
+	<m_num_bits>70</m_num_bits>
+	<m_bits>
+		<count>2</count>
+		<item_version>0</item_version>
+		<item>9223372036854775809</item>
+		<item>33</item>
+	</m_bits>
+</v2>
+<v3>
+	<m_num_bits>40</m_num_bits>
+	<m_bits>
+		<count>2</count>
+		<item_version>0</item_version>
+		<item>1</item>
+		<item>128</item>
+	</m_bits>
+</v3>
+`,
+		want: []interface{}{
+			newBitset("1000000101"),
+			*boostio.NewDynamicBitset(70, 0x8000000000000001, 0x21),
+			*boostio.NewDynamicBitset(40, 0x8000000001),
+		},
+	},
+	{
+		name: "wstring",
+		raw: `<v1>héllo 😀</v1>
+<v2></v2>
+`,
+		want: []interface{}{
+			boostio.WString("héllo 😀"),
+			boostio.WString(""),
+		},
+	},
+	{
+		name: "long-double",
+		raw: `<v1>3.14159265358979323851e+00</v1>
+<v2>-1</v2>
+<v3>inf</v3>
+<v4>-nan</v4>
+`,
+		want: []interface{}{
+			boostio.LongDouble{SignExp: 0x4000, Mantissa: 0xc90fdaa22168c235},
+			boostio.NewLongDouble(-1),
+			boostio.NewLongDouble(math.Inf(+1)),
+			boostio.LongDouble{SignExp: 0xffff, Mantissa: 0xc000000000000000},
+		},
+	},
+	{
+		name: "multiprecision",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<backend class_id="1" tracking_level="0" version="0">
+		<sign>1</sign>
+		<byte-count>16</byte-count>
+		<byte>5</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>1</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+		<byte>0</byte>
+	</backend>
+</v1>
+<v2>
+	<backend>
+		<sign>0</sign>
+		<byte-count>1</byte-count>
+		<byte>7</byte>
+	</backend>
+</v2>
+<v3 class_id="2" tracking_level="0" version="0">
+	<numerator>
+		<backend>
+			<sign>1</sign>
+			<byte-count>1</byte-count>
+			<byte>3</byte>
+		</backend>
+	</numerator>
+	<denominator>
+		<backend>
+			<sign>0</sign>
+			<byte-count>1</byte-count>
+			<byte>4</byte>
+		</backend>
+	</denominator>
+</v3>
+<v4 class_id="3" tracking_level="0" version="0">
+	<backend class_id="4" tracking_level="0" version="0">
+		<digit>1</digit>
+		<digit>50000000</digit>
+		<digit>0</digit>
+		<digit>0</digit>
+		<digit>0</digit>
+		<digit>0</digit>
+		<digit>0</digit>
+		<digit>0</digit>
+		<digit>0</digit>
+		<digit>0</digit>
+		<exponent>0</exponent>
+		<sign>0</sign>
+		<class-type>0</class-type>
+		<precision>10</precision>
+	</backend>
+</v4>
+`,
+		want: []interface{}{
+			newBigInt("-18446744073709551621"), // -(2^64+5)
+			big.NewInt(7),
+			big.NewRat(-3, 4),
+			big.NewFloat(1.5),
+		},
+	},
+	{
+		name: "date-time",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<date>20020131</date>
+</v1>
+<v2 class_id="1" tracking_level="0" version="0">
+	<ptime_date>
+		<date>20180304</date>
+	</ptime_date>
+	<ptime_time_duration class_id="2" tracking_level="0" version="1">
+		<is_special>0</is_special>
+		<time_duration_hours>10</time_duration_hours>
+		<time_duration_minutes>20</time_duration_minutes>
+		<time_duration_seconds>30</time_duration_seconds>
+		<time_duration_fractional_seconds>123456</time_duration_fractional_seconds>
+	</ptime_time_duration>
+</v2>
+<v3>
+	<is_special>0</is_special>
+	<time_duration_hours>-1</time_duration_hours>
+	<time_duration_minutes>0</time_duration_minutes>
+	<time_duration_seconds>0</time_duration_seconds>
+	<time_duration_fractional_seconds>-500000</time_duration_fractional_seconds>
+</v3>
+<v4>
+	<ptime_date>
+		<date>+infinity</date>
+	</ptime_date>
+</v4>
+<v5>
+	<is_special>1</is_special>
+	<sv_time_duration>not-a-date-time</sv_time_duration>
+</v5>
+`,
+		want: []interface{}{
+			boostio.NewDate(2002, time.January, 31),
+			time.Date(2018, time.March, 4, 10, 20, 30, 123456000, time.UTC),
+			-(time.Hour + 500*time.Millisecond),
+			boostio.PTime{Special: boostio.PosInfinity},
+			boostio.TimeDuration{Special: boostio.NotADateTime},
+		},
+	},
+	{
+		name: "uuid",
+		raw: `<v1>01234567-89ab-cdef-0123-456789abcdef</v1>
+<v2>ffffffff-ffff-ffff-ffff-ffffffffffff</v2>
+`,
+		want: []interface{}{
+			newUUID("01234567-89ab-cdef-0123-456789abcdef"),
+			newUUID("ffffffff-ffff-ffff-ffff-ffffffffffff"),
+		},
+	},
+	{
+		name: "ublas",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<data class_id="1" tracking_level="0" version="0">
+		<size>2</size>
+		<item>1.5</item>
+		<item>-2</item>
+	</data>
+</v1>
+<v2 class_id="2" tracking_level="0" version="0">
+	<size1>2</size1>
+	<size2>2</size2>
+	<data>
+		<size>4</size>
+		<item>1</item>
+		<item>2</item>
+		<item>3</item>
+		<item>4</item>
+	</data>
+</v2>
+<v3 class_id="3" tracking_level="0" version="0">
+	<size1>1</size1>
+	<size2>2</size2>
+	<data>
+		<size>2</size>
+		<item>1</item>
+		<item>2</item>
+	</data>
+</v3>
+`,
+		want: []interface{}{
+			boostio.Vector{1.5, -2},
+			*boostio.NewMatrix(2, 2, []float64{1, 2, 3, 4}),
+			boostio.Matrix{Rows: 1, Cols: 2, ColMajor: true, Data: []float64{1, 2}},
+		},
+	},
+	{
+		name: "ptree",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<count>2</count>
+	<item_version>0</item_version>
+	<item class_id="1" tracking_level="0" version="0">
+		<first>a</first>
+		<second>
+			<count>0</count>
+			<data>1</data>
+		</second>
+	</item>
+	<item>
+		<first>a</first>
+		<second>
+			<count>1</count>
+			<item_version>0</item_version>
+			<item>
+				<first>b</first>
+				<second>
+					<count>0</count>
+					<data></data>
+				</second>
+			</item>
+			<data>2</data>
+		</second>
+	</item>
+	<data>root</data>
+</v1>
+`,
+		want: []interface{}{
+			boostio.PTree{
+				Data: "root",
+				Children: []boostio.Pair[string, boostio.PTree]{
+					{First: "a", Second: boostio.PTree{Data: "1"}},
+					{First: "a", Second: boostio.PTree{
+						Data: "2",
+						Children: []boostio.Pair[string, boostio.PTree]{
+							{First: "b", Second: boostio.PTree{}},
+						},
+					}},
+				},
+			},
+		},
+	},
+	{
+		name: "histogram",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<axes class_id="1" tracking_level="0" version="0">
+		<count>2</count>
+		<item_version>0</item_version>
+		<item class_id="2" tracking_level="0" version="0">
+			<variant class_id="3" tracking_level="0" version="0">
+				<which>0</which>
+				<value class_id="4" tracking_level="0" version="0">
+					<transform class_id="5" tracking_level="0" version="0"></transform>
+					<size>2</size>
+					<meta>x</meta>
+					<min>0</min>
+					<delta>0.5</delta>
+				</value>
+			</variant>
+		</item>
+		<item>
+			<variant>
+				<which>4</which>
+				<value class_id="6" tracking_level="0" version="0">
+					<seq class_id="7" tracking_level="0" version="0">
+						<count>1</count>
+						<item_version>0</item_version>
+						<item>a</item>
+					</seq>
+					<meta></meta>
+				</value>
+			</variant>
+		</item>
+	</axes>
+	<storage class_id="8" tracking_level="0" version="0">
+		<impl class_id="9" tracking_level="0" version="0">
+			<vector>
+				<count>4</count>
+				<item_version>0</item_version>
+				<item>0</item>
+				<item>1</item>
+				<item>2</item>
+				<item>3</item>
+			</vector>
+		</impl>
+	</storage>
+</v1>
+<v2 class_id="10" tracking_level="0" version="0">
+	<axes>
+		<count>2</count>
+		<item_version>0</item_version>
+		<item>
+			<variant>
+				<which>1</which>
+				<value class_id="11" tracking_level="0" version="0">
+					<seq>
+						<count>2</count>
+						<item_version>0</item_version>
+						<item>0</item>
+						<item>1</item>
+					</seq>
+					<meta>v</meta>
+				</value>
+			</variant>
+		</item>
+		<item>
+			<variant>
+				<which>0</which>
+				<value class_id="12" tracking_level="0" version="0">
+					<size>1</size>
+					<meta></meta>
+					<min>-1</min>
+				</value>
+			</variant>
+		</item>
+	</axes>
+	<storage class_id="13" tracking_level="0" version="0">
+		<impl class_id="14" tracking_level="0" version="0">
+			<vector class_id="15" tracking_level="0" version="0">
+				<count>2</count>
+				<item_version>0</item_version>
+				<item class_id="16" tracking_level="0" version="0">
+					<sum_of_weights>1</sum_of_weights>
+					<sum_of_weights_squared>1</sum_of_weights_squared>
+				</item>
+				<item>
+					<sum_of_weights>2</sum_of_weights>
+					<sum_of_weights_squared>4</sum_of_weights_squared>
+				</item>
+			</vector>
+		</impl>
+	</storage>
+</v2>
+`,
+		want: []interface{}{
+			boostio.Histogram{
+				Axes: []boostio.Axis{
+					boostio.RegularAxis{Size: 2, Meta: "x", Min: 0, Delta: 0.5},
+					boostio.StrCategoryAxis{Values: []string{"a"}},
+				},
+				Counts: []int32{0, 1, 2, 3},
+			},
+			boostio.Histogram{
+				Variant: []boostio.AxisKind{boostio.IntegerAxisKind, boostio.VariableAxisKind},
+				Axes: []boostio.Axis{
+					boostio.VariableAxis{Edges: []float64{0, 1}, Meta: "v"},
+					boostio.IntegerAxis{Size: 1, Min: -1},
+				},
+				Weighted: true,
+				Weights:  []boostio.WeightedSum{{SumOfWeights: 1, SumOfWeightsSquared: 1}, {SumOfWeights: 2, SumOfWeightsSquared: 4}},
+			},
+		},
+	},
+	{
+		name: "multi-index",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<IDs class_id="1" tracking_level="0" version="2">
+		<count>2</count>
+		<value_version class_id="2" tracking_level="0" version="0"></value_version>
+		<item>3</item>
+		<position class_id="3" tracking_level="0" version="0"></position>
+		<item>1</item>
+		<position></position>
+		<position></position>
+		<buckets class_id="4" tracking_level="0" version="0"></buckets>
+	</IDs>
+</v1>
+<v2 class_id="5" tracking_level="0" version="0">
+	<Orders class_id="6" tracking_level="0" version="2">
+		<count>3</count>
+		<value_version class_id="7" tracking_level="0" version="0"></value_version>
+		<item class_id="8" tracking_level="0" version="0">
+			<ID>7</ID>
+		</item>
+		<position class_id="9" tracking_level="1" version="0" object_id="_0"></position>
+		<item>
+			<ID>8</ID>
+		</item>
+		<position object_id="_1"></position>
+		<item>
+			<ID>9</ID>
+		</item>
+		<position object_id="_2"></position>
+		<position object_id="_3"></position>
+		<pointer class_id_reference="9" object_id_reference="_3"></pointer>
+		<pointer class_id="-1"></pointer>
+		<pointer class_id_reference="9" object_id_reference="_2"></pointer>
+		<pointer class_id_reference="9" object_id_reference="_3"></pointer>
+	</Orders>
+</v2>
+`,
+		want: []interface{}{
+			book{IDs: []int32{3, 1}},
+			queue{Orders: []order{{ID: 7}, {ID: 8}, {ID: 9}}},
+		},
+	},
+	{
+		name: "enum",
+		raw: `<v1>2</v1>
+<v2 class_id="0" tracking_level="0" version="0">
+	<Color>1</Color>
+	<Alpha>-1</Alpha>
+</v2>
+<v3 class_id="1" tracking_level="0" version="0">
+	<count>2</count>
+	<item_version>0</item_version>
+	<item>0</item>
+	<item>2</item>
+</v3>
+`,
+		want: []interface{}{
+			blue,
+			pixel{Color: green, Alpha: -1},
+			[]color{red, blue},
+		},
+	},
+	{
+		name: "c-array",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<Cells>
+		<count>2</count>
+		<item>
+			<count>3</count>
+			<item>1</item>
+			<item>2</item>
+			<item>3</item>
+		</item>
+		<item>
+			<count>3</count>
+			<item>4</item>
+			<item>5</item>
+			<item>6</item>
+		</item>
+	</Cells>
+	<Orders>
+		<count>2</count>
+		<item class_id="1" tracking_level="0" version="0">
+			<ID>7</ID>
+		</item>
+		<item>
+			<ID>8</ID>
+		</item>
+	</Orders>
+	<Dims class_id="2" tracking_level="0" version="0">
+		<elems>
+			<count>2</count>
+			<item>9</item>
+			<item>10</item>
+		</elems>
+	</Dims>
+</v1>
+`,
+		want: []interface{}{
+			grid{
+				Cells:  [2][3]int16{{1, 2, 3}, {4, 5, 6}},
+				Orders: [2]order{{ID: 7}, {ID: 8}},
+				Dims:   [2]int32{9, 10},
+			},
+		},
+	},
+	{
+		name: "headerless-array",
+		raw: `<v1 class_id="0" tracking_level="0" version="0">
+	<Count>3</Count>
+	<Values>
+		<item>1</item>
+		<item>2</item>
+		<item>3</item>
+	</Values>
+	<Size>2</Size>
+	<Data>yv4=</Data>
+	<Magic>
+Qk9P
+Uw==
+</Magic>
+	<Orders>
+		<item class_id="1" tracking_level="0" version="0">
+			<ID>7</ID>
+		</item>
+		<item>
+			<ID>8</ID>
+		</item>
+	</Orders>
+</v1>
+`,
+		want: []interface{}{
+			blob{
+				Count:  3,
+				Values: []float32{1, 2, 3},
+				Size:   2,
+				Data:   []byte{0xca, 0xfe},
+				Magic:  [4]byte{'B', 'O', 'O', 'S'},
+				Orders: []order{{ID: 7}, {ID: 8}},
+			},
+		},
+	},
+}
+
+// equal reports whether got and want are deeply equal.
+// math/big values are compared by value.
+func equal(got, want interface{}) bool {
+	switch want := want.(type) {
+	case *big.Int:
+		return want.Cmp(got.(*big.Int)) == 0
+	case *big.Float:
+		return want.Cmp(got.(*big.Float)) == 0
+	case *big.Rat:
+		return want.Cmp(got.(*big.Rat)) == 0
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func newBigInt(s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic(fmt.Errorf("invalid integer %q", s))
+	}
+	return v
+}
+
+func newUUID(s string) boostio.UUID {
+	u, err := boostio.ParseUUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func newBitset(s string) boostio.Bitset {
+	b, err := boostio.ParseBitset(s)
+	if err != nil {
+		panic(err)
+	}
+	return *b
+}
+
+// newValue returns a value, ready to be decoded, of the same type than v.
+func newValue(v interface{}) reflect.Value {
+	rv := reflect.New(reflect.TypeOf(v)).Elem()
+	switch v := v.(type) {
+	case boostio.Bitset:
+		rv.Set(reflect.ValueOf(*boostio.NewBitset(v.Len())))
+	case boostio.Matrix:
+		rv.Set(reflect.ValueOf(boostio.Matrix{ColMajor: v.ColMajor}))
+	case boostio.Histogram:
+		rv.Set(reflect.ValueOf(boostio.Histogram{Variant: v.Variant, Weighted: v.Weighted}))
+	}
+	if rv.Kind() == reflect.Map {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+	return rv
+}
+
+func TestDecoderArchive(t *testing.T) {
+	for _, tc := range archiveTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := xmlser.NewDecoder(strings.NewReader(archive(tc.raw)))
+			for _, want := range tc.want {
+				rv := newValue(want)
+				err := dec.Decode(rv.Addr().Interface())
+				if err != nil {
+					t.Fatalf("could not decode %T: %v", want, err)
+				}
+				if got := rv.Interface(); !equal(got, want) {
+					t.Fatalf("got=%#v\nwant=%#v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidEnum(t *testing.T) {
+	for _, raw := range []string{
+		"<v1>7</v1>\n",
+		"<v1>-1</v1>\n",
+		"<v1>256</v1>\n",
+	} {
+		var c color
+		err := xmlser.NewDecoder(strings.NewReader(archive(raw))).Decode(&c)
+		if !errors.Is(err, xmlser.ErrInvalidEnum) {
+			t.Fatalf("%q: invalid error: got=%v, want=%v", raw, err, xmlser.ErrInvalidEnum)
+		}
+	}
+}
+
+func TestInvalidMatrix(t *testing.T) {
+	// (1<<62+1)*4 overflows to 4, the number of elements.
+	raw := archive(`<v1 class_id="0" tracking_level="0" version="0">
+	<size1>4611686018427387905</size1>
+	<size2>4</size2>
+	<data class_id="1" tracking_level="0" version="0">
+		<size>4</size>
+		<item>1</item>
+		<item>2</item>
+		<item>3</item>
+		<item>4</item>
+	</data>
+</v1>
+`)
+	var m boostio.Matrix
+	err := xmlser.NewDecoder(strings.NewReader(raw)).Decode(&m)
+	if !errors.Is(err, xmlser.ErrInvalidMatrix) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, xmlser.ErrInvalidMatrix)
+	}
+}
+
+func TestInvalidLenField(t *testing.T) {
+	raw := archive(`<v1 class_id="0" tracking_level="0" version="0">
+	<Values>
+		<item>1</item>
+	</Values>
+	<N>1</N>
+</v1>
+`)
+	// the number of elements must be read before the elements.
+	var v struct {
+		Values []int32 `boost:",array,len=N"`
+		N      int32
+	}
+	err := xmlser.NewDecoder(strings.NewReader(raw)).Decode(&v)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	const (
+		str    = "<v1>abc</v1>\n"
+		slice  = "<v1>\n\t<count>3</count>\n\t<item_version>0</item_version>\n\t<item>1</item>\n\t<item>2</item>\n\t<item>3</item>\n</v1>\n"
+		slices = `<v1 class_id="0" tracking_level="0" version="0">
+	<count>2</count>
+	<item_version>0</item_version>
+	<item>
+		<count>1</count>
+		<item_version>0</item_version>
+		<item>1</item>
+	</item>
+	<item>
+		<count>1</count>
+		<item_version>0</item_version>
+		<item>2</item>
+	</item>
+</v1>
+`
+	)
+
+	for _, tc := range []struct {
+		name string
+		raw  string
+		ptr  interface{}
+		opts []xmlser.Option
+		want error
+	}{
+		{
+			name: "max-string-len",
+			raw:  str,
+			ptr:  new(string),
+			opts: []xmlser.Option{xmlser.WithMaxStringLen(2)},
+			want: xmlser.ErrLimitExceeded,
+		},
+		{
+			name: "max-string-len-ok",
+			raw:  str,
+			ptr:  new(string),
+			opts: []xmlser.Option{xmlser.WithMaxStringLen(3)},
+		},
+		{
+			name: "max-collection-len",
+			raw:  slice,
+			ptr:  new([]int32),
+			opts: []xmlser.Option{xmlser.WithMaxCollectionLen(2)},
+			want: xmlser.ErrLimitExceeded,
+		},
+		{
+			name: "max-collection-len-huge",
+			raw:  "<v1>\n\t<count>18446744073709551615</count>\n\t<item_version>0</item_version>\n</v1>\n",
+			ptr:  new([]int32),
+			want: xmlser.ErrLimitExceeded,
+		},
+		{
+			name: "max-depth",
+			raw:  slices,
+			ptr:  new([][]int32),
+			opts: []xmlser.Option{xmlser.WithMaxDepth(2)},
+			want: xmlser.ErrLimitExceeded,
+		},
+		{
+			name: "max-depth-ok",
+			raw:  slices,
+			ptr:  new([][]int32),
+			opts: []xmlser.Option{xmlser.WithMaxDepth(3)},
+		},
+		{
+			name: "max-bytes",
+			raw:  slice,
+			ptr:  new([]int32),
+			opts: []xmlser.Option{xmlser.WithMaxBytes(250)},
+			want: xmlser.ErrLimitExceeded,
+		},
+		{
+			name: "max-bytes-ok",
+			raw:  slice,
+			ptr:  new([]int32),
+			opts: []xmlser.Option{xmlser.WithMaxBytes(1024)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := xmlser.NewDecoder(strings.NewReader(archive(tc.raw)), tc.opts...)
+			err := dec.Decode(tc.ptr)
+			switch {
+			case tc.want == nil && err != nil:
+				t.Fatalf("could not decode: %v", err)
+			case !errors.Is(err, tc.want):
+				t.Fatalf("invalid error: got=%v, want=%v", err, tc.want)
+			}
+		})
+	}
+}
+
+// countReader reads from r, counting the bytes read.
+type countReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestDecoderStringLimit(t *testing.T) {
+	const n = 1 << 20
+	raw := archive("<v1>" + strings.Repeat("a", n) + "</v1>\n")
+	r := &countReader{r: strings.NewReader(raw)}
+
+	var v string
+	err := xmlser.NewDecoder(r, xmlser.WithMaxStringLen(16)).Decode(&v)
+	if !errors.Is(err, xmlser.ErrLimitExceeded) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, xmlser.ErrLimitExceeded)
+	}
+	if r.n >= n/2 {
+		t.Fatalf("string read in full: read %d bytes out of %d", r.n, len(raw))
+	}
+
+	// entity references may expand the XML form of a string up to 6 times.
+	raw = archive("<v1>" + strings.Repeat("&quot;", 16) + "</v1>\n")
+	err = xmlser.NewDecoder(strings.NewReader(raw), xmlser.WithMaxStringLen(16)).Decode(&v)
+	if err != nil {
+		t.Fatalf("could not decode: %v", err)
+	}
+	if want := strings.Repeat(`"`, 16); v != want {
+		t.Fatalf("invalid value: got=%q, want=%q", v, want)
+	}
+}
+
+func TestDecoderErrorPath(t *testing.T) {
+	raw := archive(`<v1 class_id="0" tracking_level="0" version="0">
+	<count>2</count>
+	<item_version>0</item_version>
+	<item class_id="1" tracking_level="0" version="0">
+		<Color>1</Color>
+		<Alpha>-1</Alpha>
+	</item>
+	<item>
+		<Color>7</Color>
+		<Alpha>-1</Alpha>
+	</item>
+</v1>
+`)
+
+	var v []pixel
+	err := xmlser.NewDecoder(strings.NewReader(raw)).Decode(&v)
+	if !errors.Is(err, xmlser.ErrInvalidEnum) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, xmlser.ErrInvalidEnum)
+	}
+
+	var e *xmlser.DecodeError
+	if !errors.As(err, &e) {
+		t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+	}
+	if got, want := e.Path, "[]xmlser_test.pixel[1].Color"; got != want {
+		t.Fatalf("invalid path: got=%q, want=%q", got, want)
+	}
+	if got, want := [2]int{e.Line, e.Column}, [2]int{12, 19}; got != want {
+		t.Fatalf("invalid position: got=%d, want=%d", got, want)
+	}
+}
+
+func TestDecoderNestedError(t *testing.T) {
+	type item struct {
+		ID   int32
+		Size int // not supported.
+	}
+
+	raw := archive(`<v1 class_id="0" tracking_level="0" version="0">
+	<count>1</count>
+	<item_version>0</item_version>
+	<item class_id="1" tracking_level="0" version="0">
+		<ID>1</ID>
+		<Size>2</Size>
+	</item>
+</v1>
+<v2>3</v2>
+`)
+
+	dec := xmlser.NewDecoder(strings.NewReader(raw))
+	var v []item
+	err := dec.Decode(&v)
+	if !errors.Is(err, xmlser.ErrTypeNotSupported) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, xmlser.ErrTypeNotSupported)
+	}
+	var e *xmlser.DecodeError
+	if !errors.As(err, &e) {
+		t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+	}
+	if got, want := e.Path, "[]xmlser_test.item[0].Size"; got != want {
+		t.Fatalf("invalid path: got=%q, want=%q", got, want)
+	}
+
+	if got := dec.Decode(new(int32)); got != err {
+		t.Fatalf("decoder not in failed state: got=%v, want=%v", got, err)
+	}
+
+	raw = archive(`<v1 class_id="0" tracking_level="0" version="0">
+	<elems>
+		<count>2</count>
+		<item>1</item>
+		<item>2</item>
+	</elems>
+</v1>
+<v2>3</v2>
+`)
+
+	// errors before any input is read leave the decoder usable.
+	dec = xmlser.NewDecoder(strings.NewReader(raw))
+	err = dec.Decode(new(int))
+	if !errors.Is(err, xmlser.ErrTypeNotSupported) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, xmlser.ErrTypeNotSupported)
+	}
+
+	// errors of a partly read top-level value are sticky.
+	err = dec.Decode(new([3]int32))
+	if !errors.Is(err, xmlser.ErrInvalidArrayLen) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, xmlser.ErrInvalidArrayLen)
+	}
+	if got := dec.Decode(new(int32)); got != err {
+		t.Fatalf("decoder not in failed state: got=%v, want=%v", got, err)
+	}
+}
+
+func TestDecoderStrict(t *testing.T) {
+	t.Run("testdata/data.xml", func(t *testing.T) {
+		raw, err := os.ReadFile("testdata/data.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dec := xmlser.NewDecoder(bytes.NewReader(raw), xmlser.WithStrict())
+		for _, tc := range typeTestCases {
+			ptr := reflect.New(reflect.TypeOf(tc.want))
+			err := dec.Decode(ptr.Interface())
+			if err != nil {
+				t.Fatalf("could not read %q: %v", tc.name, err)
+			}
+		}
+		if err := dec.Finish(); err != nil {
+			t.Fatalf("could not finish: %v", err)
+		}
+	})
+
+	for _, tc := range archiveTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := xmlser.NewDecoder(strings.NewReader(archive(tc.raw)), xmlser.WithStrict())
+			for _, want := range tc.want {
+				rv := newValue(want)
+				err := dec.Decode(rv.Addr().Interface())
+				if err != nil {
+					t.Fatalf("could not decode %T: %v", want, err)
+				}
+			}
+			if err := dec.Finish(); err != nil {
+				t.Fatalf("could not finish: %v", err)
+			}
+		})
+	}
+
+	const durations = `<v1 class_id="0" tracking_level="0" version="0">
+	<count>1</count>
+	<item_version>%d</item_version>
+	<item class_id="1" tracking_level="0" version="1">
+		<is_special>0</is_special>
+		<time_duration_hours>1</time_duration_hours>
+		<time_duration_minutes>0</time_duration_minutes>
+		<time_duration_seconds>0</time_duration_seconds>
+		<time_duration_fractional_seconds>0</time_duration_fractional_seconds>
+	</item>
+</v1>
+`
+	const orders = `<v1 class_id="0" tracking_level="0" version="%d">
+	<count>1</count>
+	<item_version>%d</item_version>
+	<item class_id="1" tracking_level="0" version="0">
+		<ID>1</ID>
+	</item>
+</v1>
+`
+
+	for _, tc := range []struct {
+		name string
+		raw  string
+		ptrs []interface{}
+		want error // error in strict mode.
+	}{
+		{
+			name: "ok",
+			raw:  fmt.Sprintf(orders, 0, 0),
+			ptrs: []interface{}{new([]order)},
+		},
+		{
+			name: "time-durations",
+			raw:  fmt.Sprintf(durations, 1),
+			ptrs: []interface{}{new([]boostio.TimeDuration)},
+		},
+		{
+			name: "archive-version",
+			raw:  strings.Replace(archive(fmt.Sprintf(orders, 0, 0)), `version="17"`, `version="42"`, 1),
+			ptrs: []interface{}{new([]order)},
+			want: xmlser.ErrInvalidHeader,
+		},
+		{
+			name: "class-version",
+			raw:  fmt.Sprintf(orders, 1, 0),
+			ptrs: []interface{}{new([]order)},
+			want: xmlser.ErrInvalidTypeDescr,
+		},
+		{
+			name: "item-version",
+			raw:  fmt.Sprintf(durations, 0),
+			ptrs: []interface{}{new([]boostio.TimeDuration)},
+			want: xmlser.ErrItemVersion,
+		},
+		{
+			name: "user-item-version",
+			raw:  fmt.Sprintf(orders, 0, 1),
+			ptrs: []interface{}{new([]order)},
+			want: xmlser.ErrInvalidTypeDescr,
+		},
+		{
+			name: "user-class-version",
+			raw: `<v1 class_id="0" tracking_level="0" version="3">
+	<ID>1</ID>
+</v1>
+<v2 class_id="1" tracking_level="0" version="3">
+	<Color>1</Color>
+	<Alpha>-1</Alpha>
+</v2>
+`,
+			ptrs: []interface{}{new(order), new(pixel)},
+		},
+		{
+			name: "class-id",
+			raw: `<v1 class_id="0" tracking_level="0" version="3">
+	<ID>1</ID>
+</v1>
+<v2 class_id="0" tracking_level="0" version="0">
+	<Color>1</Color>
+	<Alpha>-1</Alpha>
+</v2>
+`,
+			ptrs: []interface{}{new(order), new(pixel)},
+			want: xmlser.ErrInvalidTypeDescr,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := tc.raw
+			if !strings.HasPrefix(raw, "<?xml") {
+				raw = archive(raw)
+			}
+			decode := func(opts ...xmlser.Option) error {
+				dec := xmlser.NewDecoder(strings.NewReader(raw), opts...)
+				for _, ptr := range tc.ptrs {
+					ptr := reflect.New(reflect.TypeOf(ptr).Elem()).Interface()
+					if err := dec.Decode(ptr); err != nil {
+						return err
+					}
+				}
+				return dec.Finish()
+			}
+
+			if err := decode(); err != nil {
+				t.Fatalf("could not decode in lax mode: %v", err)
+			}
+			if err := decode(xmlser.WithStrict()); !errors.Is(err, tc.want) {
+				t.Fatalf("invalid error: got=%v, want=%v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecoderFinish(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  string
+		n    int // number of values decoded.
+		want error
+		pos  [2]int // line and column of the error.
+	}{
+		{
+			name: "all",
+			raw:  archive("<v1>1</v1>\n<v2>2</v2>\n<!-- done -->\n"),
+			n:    2,
+		},
+		{
+			name: "trailing-value",
+			raw:  archive("<v1>1</v1>\n<v2>2</v2>\n"),
+			n:    1,
+			want: xmlser.ErrTrailingData,
+			pos:  [2]int{5, 5},
+		},
+		{
+			name: "trailing-text",
+			raw:  archive("<v1>1</v1>\ngarbage\n"),
+			n:    1,
+			want: xmlser.ErrTrailingData,
+			pos:  [2]int{6, 1},
+		},
+		{
+			name: "trailing-element",
+			raw:  archive("<v1>1</v1>\n") + "<v2>2</v2>\n",
+			n:    1,
+			want: xmlser.ErrTrailingData,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := xmlser.NewDecoder(strings.NewReader(tc.raw))
+			for i := 0; i < tc.n; i++ {
+				var v int32
+				if err := dec.Decode(&v); err != nil {
+					t.Fatalf("could not decode value %d: %v", i, err)
+				}
+			}
+
+			err := dec.Finish()
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("invalid error: got=%v, want=%v", err, tc.want)
+			}
+			if tc.want == nil || tc.pos == [2]int{} {
+				return
+			}
+			var e *xmlser.DecodeError
+			if !errors.As(err, &e) {
+				t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+			}
+			if got := [2]int{e.Line, e.Column}; got != tc.pos {
+				t.Fatalf("invalid position: got=%d, want=%d", got, tc.pos)
+			}
+		})
+	}
+
+	t.Run("unclosed", func(t *testing.T) {
+		raw := strings.TrimSuffix(archive("<v1>1</v1>\n"), "</boost_serialization>\n")
+		dec := xmlser.NewDecoder(strings.NewReader(raw))
+		var v int32
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("could not decode: %v", err)
+		}
+		var e *xml.SyntaxError
+		if err := dec.Finish(); !errors.As(err, &e) {
+			t.Fatalf("invalid error: got=%v, want=%T", err, e)
+		}
+	})
+}
+
+func TestCollection(t *testing.T) {
+	t.Run("testdata/data.xml", func(t *testing.T) {
+		f, err := os.Open("testdata/data.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		dec := xmlser.NewDecoder(f)
+		var want []manimal
+		for _, tc := range typeTestCases {
+			if tc.name == "[]animal" {
+				want = tc.want.([]manimal)
+				break
+			}
+			ptr := reflect.New(reflect.TypeOf(tc.want))
+			if err := dec.Decode(ptr.Interface()); err != nil {
+				t.Fatalf("could not read %q: %v", tc.name, err)
+			}
+		}
+
+		c, err := dec.OpenCollection(new([]manimal))
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		if got, want := c.Len(), len(want); got != want {
+			t.Fatalf("invalid length: got=%d, want=%d", got, want)
+		}
+		var v manimal
+		for i := 0; c.More(); i++ {
+			if err := c.Decode(&v); err != nil {
+				t.Fatalf("could not decode element %d: %v", i, err)
+			}
+			if v != want[i] {
+				t.Fatalf("invalid element %d: got=%#v, want=%#v", i, v, want[i])
+			}
+		}
+	})
+
+	const orders = `<v1 class_id="0" tracking_level="0" version="0">
+	<count>3</count>
+	<item_version>0</item_version>
+	<item class_id="1" tracking_level="0" version="0">
+		<ID>0</ID>
+	</item>
+	<item>
+		<ID>1</ID>
+	</item>
+	<item>
+		<ID>2</ID>
+	</item>
+</v1>
+<v2 class_id="2" tracking_level="0" version="0">
+	<count>0</count>
+	<item_version>0</item_version>
+</v2>
+<v3>42</v3>
+`
+
+	t.Run("stream", func(t *testing.T) {
+		dec := xmlser.NewDecoder(strings.NewReader(archive(orders)), xmlser.WithStrict())
+		c, err := dec.OpenCollection(new([]order))
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		if got, want := c.Len(), 3; got != want {
+			t.Fatalf("invalid length: got=%d, want=%d", got, want)
+		}
+
+		var v order
+		for i := 0; c.More(); i++ {
+			if err := c.Decode(&v); err != nil {
+				t.Fatalf("could not decode element %d: %v", i, err)
+			}
+			if got, want := v.ID, int32(i); got != want {
+				t.Fatalf("invalid element %d: got=%d, want=%d", i, got, want)
+			}
+		}
+		if err := c.Decode(&v); err != io.EOF {
+			t.Fatalf("invalid error: got=%v, want=%v", err, io.EOF)
+		}
+
+		c, err = dec.OpenCollection(new([]int32))
+		if err != nil {
+			t.Fatalf("could not open empty collection: %v", err)
+		}
+		if c.More() {
+			t.Fatalf("empty collection has elements")
+		}
+
+		var tail int32
+		if err := dec.Decode(&tail); err != nil {
+			t.Fatalf("could not decode value after collections: %v", err)
+		}
+		if tail != 42 {
+			t.Fatalf("invalid value after collections: got=%d, want=42", tail)
+		}
+		if err := dec.Finish(); err != nil {
+			t.Fatalf("could not finish: %v", err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		raw := strings.Replace(archive(orders), "<ID>1</ID>", "<ID>x</ID>", 1)
+		dec := xmlser.NewDecoder(strings.NewReader(raw))
+		c, err := dec.OpenCollection(new([]order))
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		var v order
+		if err := c.Decode(&v); err != nil {
+			t.Fatalf("could not decode first element: %v", err)
+		}
+		err = c.Decode(&v)
+		var e *xmlser.DecodeError
+		if !errors.As(err, &e) {
+			t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+		}
+		if got, want := e.Path, "[]xmlser_test.order[1].ID"; got != want {
+			t.Fatalf("invalid path: got=%q, want=%q", got, want)
+		}
+		if got := c.Decode(&v); got != err {
+			t.Fatalf("decoder not in failed state: got=%v, want=%v", got, err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		raw := archive(orders)
+		raw = raw[:strings.Index(raw, "<ID>1</ID>")]
+		dec := xmlser.NewDecoder(strings.NewReader(raw))
+		c, err := dec.OpenCollection(new([]order))
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		var (
+			v    order
+			errs []error
+		)
+		for c.More() {
+			if err := c.Decode(&v); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) != 1 {
+			t.Fatalf("invalid number of errors: got=%d, want=1 (%v)", len(errs), errs)
+		}
+		var e *xmlser.DecodeError
+		if !errors.As(errs[0], &e) {
+			t.Fatalf("invalid error type: got=%T, want=%T", errs[0], e)
+		}
+	})
+
+	t.Run("wrong-type", func(t *testing.T) {
+		dec := xmlser.NewDecoder(strings.NewReader(archive(orders)))
+		c, err := dec.OpenCollection(new([]order))
+		if err != nil {
+			t.Fatalf("could not open collection: %v", err)
+		}
+		err = c.Decode(new(int32))
+		if !errors.Is(err, xmlser.ErrTypeNotSupported) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, xmlser.ErrTypeNotSupported)
+		}
+		var v order
+		if err := c.Decode(&v); err != nil {
+			t.Fatalf("could not decode first element: %v", err)
+		}
+		if v.ID != 0 {
+			t.Fatalf("invalid first element: got=%d, want=0", v.ID)
+		}
+	})
+
+	t.Run("not-a-slice", func(t *testing.T) {
+		dec := xmlser.NewDecoder(strings.NewReader(archive(orders)))
+		_, err := dec.OpenCollection(new(order))
+		if !errors.Is(err, xmlser.ErrTypeNotSupported) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, xmlser.ErrTypeNotSupported)
+		}
+	})
+}
+
+type herd struct {
+	Name    string
+	Animals []animal `boost:",skip"`
+	Count   int32
+}
+
+// seen records the values it decodes, in place.
+type seen map[int32]bool
+
+func (s *seen) UnmarshalBoostXML(r *xmlser.RBuffer) error {
+	if *s == nil {
+		*s = make(seen)
+	}
+	(*s)[r.ReadI32()] = true
+	return r.Err()
+}
+
+func TestDecoderSkip(t *testing.T) {
+	for parity := 0; parity < 2; parity++ {
+		t.Run(fmt.Sprintf("testdata/data.xml-%d", parity), func(t *testing.T) {
+			raw, err := os.ReadFile("testdata/data.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dec := xmlser.NewDecoder(bytes.NewReader(raw), xmlser.WithStrict())
+			for i, tc := range typeTestCases {
+				rt := reflect.TypeOf(tc.want)
+				if i%2 == parity {
+					if err := dec.Skip(rt); err != nil {
+						t.Fatalf("could not skip %q: %v", tc.name, err)
+					}
+					continue
+				}
+				rv := reflect.New(rt)
+				if err := dec.Decode(rv.Interface()); err != nil {
+					t.Fatalf("could not read %q: %v", tc.name, err)
+				}
+				if got, want := rv.Elem().Interface(), tc.want; !reflect.DeepEqual(got, want) {
+					t.Fatalf("got=%#v (%T)\nwant=%#v (%T)", got, got, want, want)
+				}
+			}
+			if err := dec.Finish(); err != nil {
+				t.Fatalf("could not finish: %v", err)
+			}
+		})
+	}
+
+	const zoo = `<v1 class_id="0" tracking_level="0" version="0">
+	<Name>zoo</Name>
+	<Animals class_id="1" tracking_level="0" version="0">
+		<count>2</count>
+		<item_version>0</item_version>
+		<item class_id="2" tracking_level="0" version="0">
+			<Name>tiger</Name>
+			<Legs>4</Legs>
+			<Tails>1</Tails>
+		</item>
+		<item>
+			<Name>monkey</Name>
+			<Legs>4</Legs>
+			<Tails>1</Tails>
+		</item>
+	</Animals>
+	<Count>2</Count>
+</v1>
+<v2>
+	<count>1</count>
+	<item_version>0</item_version>
+	<item>
+		<Name>lion</Name>
+		<Legs>4</Legs>
+		<Tails>1</Tails>
+	</item>
+</v2>
+<v3 class_id="3" tracking_level="0" version="0">
+	<count>1</count>
+	<item_version>0</item_version>
+	<item class_id="4" tracking_level="0" version="0">
+		<first>cats</first>
+		<second>
+			<count>1</count>
+			<item_version>0</item_version>
+			<item>
+				<Name>tiger</Name>
+				<Legs>4</Legs>
+				<Tails>1</Tails>
+			</item>
+		</second>
+	</item>
+</v3>
+<v4>42</v4>
+`
+
+	t.Run("tag", func(t *testing.T) {
+		dec := xmlser.NewDecoder(strings.NewReader(archive(zoo)), xmlser.WithStrict())
+		h := herd{Animals: []animal{{"lion", 4, 1}}}
+		if err := dec.Decode(&h); err != nil {
+			t.Fatalf("could not decode herd: %v", err)
+		}
+		want := herd{Name: "zoo", Animals: []animal{{"lion", 4, 1}}, Count: 2}
+		if !reflect.DeepEqual(h, want) {
+			t.Fatalf("invalid herd:\ngot= %#v\nwant=%#v", h, want)
+		}
+
+		// the class information of animal is read from the skipped field.
+		var got []animal
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("could not decode animals: %v", err)
+		}
+		if want := []animal{{"lion", 4, 1}}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("invalid animals:\ngot= %#v\nwant=%#v", got, want)
+		}
+
+		if err := dec.Skip(reflect.TypeOf(map[string][]animal(nil))); err != nil {
+			t.Fatalf("could not skip map: %v", err)
+		}
+		var tail int32
+		if err := dec.Decode(&tail); err != nil {
+			t.Fatalf("could not decode value after map: %v", err)
+		}
+		if tail != 42 {
+			t.Fatalf("invalid value after map: got=%d, want=42", tail)
+		}
+		if err := dec.Finish(); err != nil {
+			t.Fatalf("could not finish: %v", err)
+		}
+	})
+
+	t.Run("special", func(t *testing.T) {
+		// X is 5 + 1<<64.
+		raw := archive(`<v1 class_id="0" tracking_level="0" version="0">
+	<X class_id="1" tracking_level="0" version="0">
+		<backend class_id="2" tracking_level="0" version="0">
+			<sign>0</sign>
+			<byte-count>9</byte-count>
+			<byte>5</byte>
+			<byte>0</byte>
+			<byte>0</byte>
+			<byte>0</byte>
+			<byte>0</byte>
+			<byte>0</byte>
+			<byte>0</byte>
+			<byte>0</byte>
+			<byte>1</byte>
+		</backend>
+	</X>
+	<S>7</S>
+	<N>42</N>
+</v1>
+`)
+		var v struct {
+			X big.Int `boost:",skip"`
+			S seen    `boost:",skip"`
+			N int32
+		}
+		v.X.SetString("999999999999999999999999999999", 10)
+		v.S = seen{1: true}
+		if err := xmlser.NewDecoder(strings.NewReader(raw)).Decode(&v); err != nil {
+			t.Fatalf("could not decode: %v", err)
+		}
+		if got, want := v.X.String(), "999999999999999999999999999999"; got != want {
+			t.Fatalf("invalid skipped big.Int: got=%s, want=%s", got, want)
+		}
+		if got, want := v.S, (seen{1: true}); !reflect.DeepEqual(got, want) {
+			t.Fatalf("invalid skipped Unmarshaler: got=%v, want=%v", got, want)
+		}
+		if v.N != 42 {
+			t.Fatalf("invalid value after skipped fields: got=%d, want=42", v.N)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		raw := archive(`<v1 class_id="0" tracking_level="0" version="0">
+	<I class_id="1" tracking_level="0" version="0">
+		<B class_id="2" tracking_level="0" version="0">
+			<bits>01000</bits>
+		</B>
+		<Bs class_id="3" tracking_level="0" version="0">
+			<count>2</count>
+			<item_version>0</item_version>
+			<item>
+				<bits>01000</bits>
+			</item>
+			<item>
+				<bits>00001</bits>
+			</item>
+		</Bs>
+	</I>
+	<N>42</N>
+</v1>
+`)
+		type inner struct {
+			B  boostio.Bitset
+			Bs []boostio.Bitset
+		}
+		var v struct {
+			I inner `boost:",skip"`
+			N int32
+		}
+		v.I = inner{*boostio.NewBitset(5), []boostio.Bitset{*boostio.NewBitset(5), *boostio.NewBitset(5)}}
+		if err := xmlser.NewDecoder(strings.NewReader(raw)).Decode(&v); err != nil {
+			t.Fatalf("could not decode: %v", err)
+		}
+		if v.I.B.Count() != 0 || v.I.Bs[0].Count() != 0 || v.I.Bs[1].Count() != 0 {
+			t.Fatalf("skipped bitsets modified: %v", v.I)
+		}
+		if v.N != 42 {
+			t.Fatalf("invalid value after skipped field: got=%d, want=42", v.N)
+		}
+
+		// the size of the bitsets is only known from the skipped field.
+		dec := xmlser.NewDecoder(strings.NewReader(raw))
+		err := dec.Skip(reflect.TypeOf(v))
+		if !errors.Is(err, xmlser.ErrTypeNotSupported) {
+			t.Fatalf("invalid error: got=%v, want=%v", err, xmlser.ErrTypeNotSupported)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		raw := archive(zoo)
+		raw = raw[:strings.Index(raw, "<Name>monkey")+len("<Name>mon")]
+		dec := xmlser.NewDecoder(strings.NewReader(raw))
+		err := dec.Skip(reflect.TypeOf(herd{}))
+		var e *xmlser.DecodeError
+		if !errors.As(err, &e) {
+			t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+		}
+		if got, want := e.Path, "herd.Animals[1].Name"; got != want {
+			t.Fatalf("invalid path: got=%q, want=%q", got, want)
+		}
+		var serr *xml.SyntaxError
+		if !errors.As(err, &serr) {
+			t.Fatalf("invalid error: got=%v, want=%T", err, serr)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		dec := xmlser.NewDecoder(strings.NewReader(archive(zoo)))
+		var e *xmlser.InvalidUnmarshalError
+		if err := dec.Skip(nil); !errors.As(err, &e) {
+			t.Fatalf("invalid error: got=%v, want=%T", err, e)
+		}
+	})
+}
+
+// cancelReader reads from r, and calls cancel once n bytes have been read.
+type cancelReader struct {
+	r      io.Reader
+	n      int
+	cancel func()
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n -= n
+	if r.n <= 0 {
+		r.cancel()
+	}
+	return n, err
+}
+
+func TestDecoderContext(t *testing.T) {
+	raw := new(strings.Builder)
+	raw.WriteString("<v1>\n\t<count>10000</count>\n\t<item_version>0</item_version>\n")
+	for i := 0; i < 10000; i++ {
+		raw.WriteString("\t<item>0</item>\n")
+	}
+	raw.WriteString("</v1>\n")
+
+	for _, tc := range []struct {
+		name string
+		n    int // number of bytes read before the context is cancelled.
+		path string
+	}{
+		{name: "before", n: 0, path: "[]int32"},
+		{name: "during", n: 20000, path: "[]int32[1024]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			dec := xmlser.NewDecoder(&cancelReader{r: strings.NewReader(archive(raw.String())), n: tc.n, cancel: cancel})
+
+			var v []int32
+			err := dec.DecodeContext(ctx, &v)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("invalid error: got=%v, want=%v", err, context.Canceled)
+			}
+			var e *xmlser.DecodeError
+			if !errors.As(err, &e) {
+				t.Fatalf("invalid error type: got=%T, want=%T", err, e)
+			}
+			if got, want := e.Path, tc.path; got != want {
+				t.Fatalf("invalid path: got=%q, want=%q", got, want)
+			}
+
+			if got := dec.Decode(&v); got != err {
+				t.Fatalf("decoder not in failed state: got=%v, want=%v", got, err)
+			}
+		})
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	// each target is a sequence of types decoded one after the other.
+	var targets [][]reflect.Type
+	for _, tc := range archiveTestCases {
+		var types []reflect.Type
+		for _, want := range tc.want {
+			types = append(types, reflect.TypeOf(want))
+		}
+		f.Add(uint(len(targets)), []byte(archive(tc.raw)))
+		targets = append(targets, types)
+	}
+
+	raw, err := os.ReadFile("testdata/data.xml")
+	if err != nil {
+		f.Fatal(err)
+	}
+	var types []reflect.Type
+	for _, tc := range typeTestCases {
+		types = append(types, reflect.TypeOf(tc.want))
+	}
+	f.Add(uint(len(targets)), raw)
+	targets = append(targets, types)
+
+	f.Fuzz(func(t *testing.T, i uint, raw []byte) {
+		dec := xmlser.NewDecoder(
+			bytes.NewReader(raw),
+			xmlser.WithMaxStringLen(1<<12),
+			xmlser.WithMaxCollectionLen(1<<12),
+			xmlser.WithMaxDepth(64),
+		)
+		for _, rt := range targets[i%uint(len(targets))] {
+			err := dec.Decode(reflect.New(rt).Interface())
+			if errors.Is(err, xmlser.ErrInternal) {
+				t.Fatalf("could not decode %v: %+v", rt, err)
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+}
+
+// panicker panics when decoded.
+type panicker struct{}
+
+func (*panicker) UnmarshalBoostXML(r *xmlser.RBuffer) error { panic("boom") }
+
+func TestDecoderPanicFree(t *testing.T) {
+	raw := archive("<v1>1</v1>\n")
+
+	for _, ptr := range []interface{}{nil, int32(1), (*int32)(nil)} {
+		err := xmlser.NewDecoder(strings.NewReader(raw)).Decode(ptr)
+		var e *xmlser.InvalidUnmarshalError
+		if !errors.As(err, &e) {
+			t.Fatalf("invalid error for %#v: got=%v, want=%T", ptr, err, e)
+		}
+	}
+
+	// panics of user Unmarshalers are not recovered.
+	nested := archive("<v1 class_id=\"0\" tracking_level=\"0\" version=\"0\">\n\t<P>1</P>\n</v1>\n")
+	for _, tc := range []struct {
+		raw string
+		ptr interface{}
+	}{
+		{raw, &panicker{}},
+		{nested, &struct{ P panicker }{}},
+	} {
+		ptr := tc.ptr
+		dec := xmlser.NewDecoder(strings.NewReader(tc.raw))
+		func() {
+			defer func() {
+				if e := recover(); e != "boom" {
+					t.Fatalf("invalid panic for %T: got=%v, want=%v", ptr, e, "boom")
+				}
+			}()
+			_ = dec.Decode(ptr)
+		}()
+		if err := dec.Decode(new(int32)); err == nil {
+			t.Fatalf("decoder not in failed state after a panic of %T", ptr)
+		}
+	}
+}
@@ -5,31 +5,67 @@
 package xmlser
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
 	"strconv"
+	"strings"
+
+	"github.com/go-boostio/boostio"
 )
 
 // A RBuffer reads values from a Boost binary serialization stream.
 type RBuffer struct {
-	r   io.Reader
-	err error
+	r    io.Reader
+	err  error
+	opts options
+
+	depth int             // nesting depth of the value being read.
+	ctx   context.Context // context of the value being read, if any.
 
-	types registry
+	types    registry
+	versions map[reflect.Type]uint32 // versions of the user classes, in strict mode.
+	classes  map[int64]TypeDescr     // class information by class ID, in strict mode.
 
-	tok xml.Token
-	dec *xml.Decoder
+	tok   xml.Token
+	dec   *xml.Decoder
+	lr    *lineReader
+	start xml.StartElement // last opened element
 }
 
 // NewRBuffer returns a new read-only buffer that reads from r.
-func NewRBuffer(r io.Reader) *RBuffer {
+func NewRBuffer(r io.Reader, opts ...Option) *RBuffer {
+	o := newOptions(opts)
+	var lr *lineReader
+	if r != nil {
+		if o.maxBytes > 0 {
+			r = &budgetReader{r: r, n: o.maxBytes}
+		}
+		lr = newLineReader(r)
+		r = lr
+	}
 	return &RBuffer{
+		opts:  o,
 		types: newRegistry(),
 		dec:   xml.NewDecoder(r),
+		lr:    lr,
 	}
 }
 
+// pos returns the line and column of the current position in the input.
+func (r *RBuffer) pos() (line, col int) {
+	if r.lr == nil {
+		return 0, 0
+	}
+	return r.lr.pos(r.dec.InputOffset())
+}
+
 func (r *RBuffer) Err() error { return r.err }
 
 func (r *RBuffer) next() {
@@ -50,7 +86,7 @@ func (r *RBuffer) ReadHeader() Header {
 		return hdr
 	}
 
-	for {
+	for r.err == nil {
 		r.next()
 		switch tok := r.tok.(type) {
 		case xml.StartElement:
@@ -70,6 +106,10 @@ func (r *RBuffer) ReadHeader() Header {
 							r.err = ErrInvalidHeader
 							return hdr
 						}
+						if r.opts.strict && (v <= 0 || v > int(boostio.Version)) {
+							r.err = fmt.Errorf("%w: unknown archive version %d", ErrInvalidHeader, v)
+							return hdr
+						}
 						hdr.Version = uint16(v)
 						return hdr
 					}
@@ -82,6 +122,49 @@ func (r *RBuffer) ReadHeader() Header {
 	return hdr
 }
 
+// ReadStartElement reads the opening tag of the next value.
+//
+// The class information attached to this tag, if any, can then be
+// retrieved with ReadTypeDescr.
+func (r *RBuffer) ReadStartElement() xml.StartElement {
+	for r.err == nil {
+		r.next()
+		switch tok := r.tok.(type) {
+		case xml.StartElement:
+			r.start = tok.Copy()
+			return r.start
+		case xml.EndElement:
+			r.err = ErrInvalidElement
+		}
+	}
+	return xml.StartElement{}
+}
+
+// ReadEndElement reads the closing tag of the current value.
+func (r *RBuffer) ReadEndElement() {
+	for r.err == nil {
+		r.next()
+		switch r.tok.(type) {
+		case xml.EndElement:
+			return
+		case xml.StartElement:
+			r.err = ErrInvalidElement
+		}
+	}
+}
+
+// skipElement reads and discards the next element, with all its content.
+func (r *RBuffer) skipElement() {
+	r.ReadStartElement()
+	if r.err == nil {
+		r.err = r.dec.Skip()
+	}
+}
+
+// ReadTypeDescr returns the class information of the given type.
+//
+// The class information is read from the attributes of the last element
+// opened with ReadStartElement, the first time a given type is seen.
 func (r *RBuffer) ReadTypeDescr(typ reflect.Type) TypeDescr {
 	if dtype, ok := r.types[typ]; ok {
 		return dtype
@@ -89,15 +172,76 @@ func (r *RBuffer) ReadTypeDescr(typ reflect.Type) TypeDescr {
 
 	var dtype TypeDescr
 	dtype.UnmarshalBoostXML(r)
-	switch r.err {
-	case nil:
-		r.types[typ] = dtype
-	default:
+	switch {
+	case r.err != nil:
 		r.err = ErrInvalidTypeDescr
+	case r.opts.strict && !r.checkVersion(typ, dtype.Version):
+		r.err = fmt.Errorf(
+			"%w: class version %d of %v, want %d",
+			ErrInvalidTypeDescr, dtype.Version, typ, r.versionOf(typ),
+		)
+	case r.opts.strict && !r.checkClassID(dtype):
+		r.err = fmt.Errorf(
+			"%w: class ID %d of %v inconsistent with its first occurrence",
+			ErrInvalidTypeDescr, dtype.ID, typ,
+		)
+	default:
+		r.types[typ] = dtype
 	}
 	return dtype
 }
 
+// readItemVersion reads the item_version of a collection whose elements are
+// of type et.
+// In strict mode, it must be the class version of et.
+func (r *RBuffer) readItemVersion(et reflect.Type) uint32 {
+	v := r.ReadU32()
+	if r.err == nil && r.opts.strict && !r.checkVersion(et, v) {
+		r.err = fmt.Errorf("%w: %d for %v, want %d", ErrItemVersion, v, et, r.versionOf(et))
+	}
+	return v
+}
+
+// checkVersion reports whether v is the version of the class of type rt:
+// the version the decoder reads for classes of the standard library or of
+// Boost, or the version first seen in the archive for user classes.
+func (r *RBuffer) checkVersion(rt reflect.Type, v uint32) bool {
+	if !isUserClass(rt) {
+		return v == classVersion(rt)
+	}
+	want, ok := r.versions[rt]
+	if !ok {
+		if r.versions == nil {
+			r.versions = make(map[reflect.Type]uint32)
+		}
+		r.versions[rt] = v
+		return true
+	}
+	return v == want
+}
+
+// versionOf returns the expected version of the class of type rt.
+func (r *RBuffer) versionOf(rt reflect.Type) uint32 {
+	if !isUserClass(rt) {
+		return classVersion(rt)
+	}
+	return r.versions[rt]
+}
+
+// checkClassID reports whether the class information dtype is consistent
+// with the one previously seen in the archive with the same class ID.
+func (r *RBuffer) checkClassID(dtype TypeDescr) bool {
+	prev, ok := r.classes[dtype.ID]
+	if !ok {
+		if r.classes == nil {
+			r.classes = make(map[int64]TypeDescr)
+		}
+		r.classes[dtype.ID] = dtype
+		return true
+	}
+	return prev == dtype
+}
+
 func (r *RBuffer) Read(p []byte) (int, error) {
 	if r.err != nil {
 		return 0, r.err
@@ -112,10 +256,662 @@ func (r *RBuffer) ReadString() string {
 		return ""
 	}
 	var v = ""
+	if max := int64(r.opts.maxStr); max > 0 && r.lr != nil && max < (math.MaxInt64-strSlack)/6 {
+		// bound the bytes the xml.Decoder may read for the string
+		// element, so a huge string is rejected before being held in
+		// memory.
+		r.lr.limit = r.lr.n + 6*max + strSlack
+		defer func() { r.lr.limit = 0 }()
+	}
 	r.err = r.dec.Decode(&v)
+	if errors.Is(r.err, errReadLimit) {
+		r.err = fmt.Errorf("%w: string length > %d", ErrLimitExceeded, r.opts.maxStr)
+		return ""
+	}
+	if r.checkLen(uint64(len(v)), r.opts.maxStr, "string length"); r.err != nil {
+		return ""
+	}
+	return v
+}
+
+// strSlack is the number of bytes, besides the ones of its content, a
+// string element may span: tags and surrounding whitespace.
+const strSlack = 1024
+
+// readLen reads the number of elements of a collection.
+func (r *RBuffer) readLen() int {
+	return r.checkLen(r.ReadU64(), r.opts.maxLen, "collection length")
+}
+
+// checkLen checks the length n read from the stream fits in an int and
+// does not exceed max, if max is not zero.
+func (r *RBuffer) checkLen(n uint64, max int, what string) int {
+	switch {
+	case r.err != nil:
+		return 0
+	case n > math.MaxInt:
+		r.err = fmt.Errorf("%w: %s %d overflows int", ErrLimitExceeded, what, n)
+		return 0
+	case max > 0 && n > uint64(max):
+		r.err = fmt.Errorf("%w: %s %d > %d", ErrLimitExceeded, what, n, max)
+		return 0
+	}
+	return int(n)
+}
+
+// readSlice reads n values with read, growing the returned slice as the
+// values are read.
+func readSlice[T any](r *RBuffer, n int, read func() T) []T {
+	if r.err != nil {
+		return nil
+	}
+	v := make([]T, 0, minInt(n, allocChunk/8))
+	for i := 0; i < n && r.err == nil; i++ {
+		r.poll(i)
+		v = append(v, read())
+	}
+	if r.err != nil {
+		return nil
+	}
 	return v
 }
 
+// enter records the reading of a nested value, checking the maximum nesting
+// depth is not exceeded. Each call to enter must be matched by a call to
+// leave.
+func (r *RBuffer) enter() {
+	r.depth++
+	if max := r.opts.maxDepth; max > 0 && r.depth > max && r.err == nil {
+		r.err = fmt.Errorf("%w: nesting depth > %d", ErrLimitExceeded, max)
+	}
+}
+
+// poll checks, every pollPeriod elements of a collection, whether the
+// context of the buffer is done, in which case its error is recorded.
+func (r *RBuffer) poll(i int) {
+	if r.ctx == nil || i%pollPeriod != 0 || r.err != nil {
+		return
+	}
+	select {
+	case <-r.ctx.Done():
+		r.err = r.ctx.Err()
+	default:
+	}
+}
+
+// leave records the end of the reading of a nested value.
+//
+// Reaching the end of the stream within a nested value is unexpected.
+func (r *RBuffer) leave() {
+	if r.depth > 1 && r.err == io.EOF {
+		r.err = io.ErrUnexpectedEOF
+	}
+	r.depth--
+}
+
+// ReadBinaryObject reads a boost::serialization::binary_object of n bytes.
+//
+// XML archives hold binary objects encoded in base64, with no count: their
+// size is known from the surrounding data.
+func (r *RBuffer) ReadBinaryObject(n int) []byte {
+	if n < 0 {
+		r.err = ErrInvalidArrayLen
+		return nil
+	}
+	if r.checkLen(uint64(n), r.opts.maxStr, "binary object length"); r.err != nil {
+		return nil
+	}
+	str := r.ReadString()
+	if r.err != nil {
+		return nil
+	}
+	str = strings.Join(strings.Fields(str), "")
+	p, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		r.err = fmt.Errorf("xmlser: invalid binary object: %w", err)
+		return nil
+	}
+	if len(p) != n {
+		r.err = ErrInvalidArrayLen
+		return nil
+	}
+	return p
+}
+
+// ReadWString reads a std::wstring.
+//
+// XML archives hold wide strings converted to UTF-8.
+func (r *RBuffer) ReadWString() string {
+	return r.ReadString()
+}
+
+// ReadLongDouble reads a C++ long double.
+func (r *RBuffer) ReadLongDouble() boostio.LongDouble {
+	str := strings.TrimSpace(r.ReadString())
+	if r.err != nil {
+		return boostio.LongDouble{}
+	}
+	switch strings.ToLower(strings.TrimLeft(str, "+-")) {
+	case "nan":
+		v := boostio.LongDouble{SignExp: 0x7fff, Mantissa: 0xc000000000000000}
+		if strings.HasPrefix(str, "-") {
+			v.SignExp |= 1 << 15
+		}
+		return v
+	}
+	v, _, err := big.ParseFloat(str, 10, 64, big.ToNearestEven)
+	if err != nil {
+		r.err = ErrInvalidFloat
+		return boostio.LongDouble{}
+	}
+	return boostio.NewLongDoubleFromBig(v)
+}
+
+// ReadBitset reads a std::bitset<n>.
+func (r *RBuffer) ReadBitset(n int) boostio.Bitset {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(bitsetOf(n))
+	str := r.ReadString()
+	r.ReadEndElement()
+	if r.err != nil {
+		return *boostio.NewBitset(n)
+	}
+	b, err := boostio.ParseBitset(str)
+	if err != nil || b.Len() != n {
+		r.err = ErrInvalidBitset
+		return *boostio.NewBitset(n)
+	}
+	return *b
+}
+
+// ReadVectorBool reads a std::vector<bool>.
+//
+// A std::vector<bool> is stored as its number of elements, followed by one
+// bool per element. Unlike other collections, it never has an item_version.
+func (r *RBuffer) ReadVectorBool() []bool {
+	r.ReadStartElement()
+	v := readSlice(r, r.readLen(), r.ReadBool)
+	r.ReadEndElement()
+	if r.err != nil {
+		return nil
+	}
+	return v
+}
+
+// ReadDynamicBitset reads a boost::dynamic_bitset<>.
+//
+// The size of the blocks (32 or 64 bits) is inferred from their number.
+func (r *RBuffer) ReadDynamicBitset() boostio.DynamicBitset {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(dynamicBitsetType)
+	n := r.readLen()
+	r.ReadStartElement() // m_bits
+	nb := r.readLen()
+	r.readItemVersion(reflect.TypeOf(uint64(0)))
+	if r.err != nil {
+		return boostio.DynamicBitset{}
+	}
+
+	var blocks []uint64
+	switch {
+	case nb == (n+63)/64:
+		blocks = readSlice(r, nb, r.ReadU64)
+	case nb == (n+31)/32:
+		words := readSlice(r, nb, r.ReadU32)
+		blocks = make([]uint64, (len(words)+1)/2)
+		for i, w := range words {
+			blocks[i/2] |= uint64(w) << (32 * uint(i%2))
+		}
+	default:
+		r.err = ErrInvalidBitset
+		return boostio.DynamicBitset{}
+	}
+	r.ReadEndElement()
+	r.ReadEndElement()
+	if r.err != nil {
+		return boostio.DynamicBitset{}
+	}
+	return *boostio.NewDynamicBitset(n, blocks...)
+}
+
+// ReadBigInt reads a boost::multiprecision::cpp_int.
+//
+// XML archives hold a cpp_int as its sign, its number of bytes and its
+// bytes, the least significant first.
+func (r *RBuffer) ReadBigInt() *big.Int {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(bigIntType)
+	r.ReadStartElement() // backend
+	_ = r.ReadTypeDescr(cppIntType)
+	neg := r.ReadBool()
+	raw := readSlice(r, r.readLen(), r.ReadU8)
+	for i, j := 0, len(raw)-1; i < j; i, j = i+1, j-1 {
+		raw[i], raw[j] = raw[j], raw[i]
+	}
+	r.ReadEndElement()
+	r.ReadEndElement()
+	if r.err != nil {
+		return new(big.Int)
+	}
+
+	v := new(big.Int).SetBytes(raw)
+	if neg {
+		v.Neg(v)
+	}
+	return v
+}
+
+// ReadBigFloat reads a boost::multiprecision::cpp_dec_float<Digits10>.
+//
+// The number of digits of the cpp_dec_float is inferred from the archive.
+func (r *RBuffer) ReadBigFloat() *big.Float {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(bigFloatType)
+	r.ReadStartElement() // backend
+	_ = r.ReadTypeDescr(cppDecFloatType)
+
+	var (
+		v     boostio.DecFloat
+		start = r.ReadStartElement()
+	)
+	for r.err == nil && start.Name.Local == "digit" {
+		var d uint32
+		r.decodeElement(&d, &start)
+		v.Data = append(v.Data, d)
+		start = r.ReadStartElement()
+	}
+	r.decodeElement(&v.Exp, &start)
+	v.Neg = r.ReadBool()
+	v.Class = r.ReadI32()
+	v.Prec = r.ReadI32()
+	r.ReadEndElement()
+	r.ReadEndElement()
+	if r.err != nil {
+		return new(big.Float)
+	}
+
+	f, err := v.BigFloat()
+	if err != nil {
+		r.err = ErrInvalidNumber
+		return new(big.Float)
+	}
+	return f
+}
+
+// ReadBigRat reads a boost::rational<cpp_int>, written as its numerator
+// and denominator.
+func (r *RBuffer) ReadBigRat() *big.Rat {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(bigRatType)
+	num := r.ReadBigInt()
+	den := r.ReadBigInt()
+	r.ReadEndElement()
+	if r.err != nil {
+		return new(big.Rat)
+	}
+	if den.Sign() == 0 {
+		r.err = ErrInvalidNumber
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(num, den)
+}
+
+// ReadUUID reads a boost::uuids::uuid, held as its canonical string form.
+func (r *RBuffer) ReadUUID() boostio.UUID {
+	str := r.ReadString()
+	if r.err != nil {
+		return boostio.UUID{}
+	}
+	u, err := boostio.ParseUUID(strings.TrimSpace(str))
+	if err != nil {
+		r.err = ErrInvalidUUID
+		return boostio.UUID{}
+	}
+	return u
+}
+
+// ReadVector reads a dense boost::numeric::ublas::vector<double>.
+func (r *RBuffer) ReadVector() boostio.Vector {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(vectorType)
+	v := r.readUnboundedArray()
+	r.ReadEndElement()
+	if r.err != nil {
+		return nil
+	}
+	return boostio.Vector(v)
+}
+
+// ReadMatrix reads a dense boost::numeric::ublas::matrix<double>, stored in
+// column-major order if colMajor is true, in row-major order otherwise.
+func (r *RBuffer) ReadMatrix(colMajor bool) boostio.Matrix {
+	r.ReadStartElement()
+	switch colMajor {
+	case true:
+		_ = r.ReadTypeDescr(colMajorMatrixType)
+	default:
+		_ = r.ReadTypeDescr(matrixType)
+	}
+	rows := r.readLen()
+	cols := r.readLen()
+	data := r.readUnboundedArray()
+	r.ReadEndElement()
+	if r.err != nil {
+		return boostio.Matrix{ColMajor: colMajor}
+	}
+	// rows*cols may overflow.
+	if (cols != 0 && rows > len(data)/cols) || len(data) != rows*cols {
+		r.err = ErrInvalidMatrix
+		return boostio.Matrix{ColMajor: colMajor}
+	}
+	return boostio.Matrix{Rows: rows, Cols: cols, ColMajor: colMajor, Data: data}
+}
+
+// readUnboundedArray reads a boost::numeric::ublas::unbounded_array<double>.
+func (r *RBuffer) readUnboundedArray() []float64 {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(unboundedArrayType)
+	v := readSlice(r, r.readLen(), r.ReadF64)
+	r.ReadEndElement()
+	if r.err != nil {
+		return nil
+	}
+	return v
+}
+
+// ReadHistogram reads a boost::histogram::histogram with a std::vector of
+// axis::variant axes, whose alternatives are listed by variant (default:
+// boostio.DefaultAxisVariant), and with a dense int storage or, if
+// weighted is true, a dense weight storage.
+func (r *RBuffer) ReadHistogram(variant []boostio.AxisKind, weighted bool) boostio.Histogram {
+	h := boostio.Histogram{Variant: variant, Weighted: weighted}
+	r.ReadStartElement()
+	switch weighted {
+	case true:
+		_ = r.ReadTypeDescr(weightedHistogramType)
+	default:
+		_ = r.ReadTypeDescr(histogramType)
+	}
+
+	r.ReadStartElement() // axes
+	_ = r.ReadTypeDescr(axesType)
+	n := r.readLen()
+	r.readItemVersion(axisVariantType)
+	kinds := h.AxisVariant()
+	for i := 0; i < n && r.err == nil; i++ {
+		r.ReadStartElement() // variant
+		_ = r.ReadTypeDescr(axisVariantType)
+		r.ReadStartElement() // variant proxy
+		_ = r.ReadTypeDescr(axisVariantProxyType)
+		which := int(r.ReadI32())
+		if r.err != nil {
+			break
+		}
+		if which < 0 || which >= len(kinds) {
+			r.err = ErrInvalidHistogram
+			break
+		}
+		h.Axes = append(h.Axes, r.readAxis(kinds[which]))
+		r.ReadEndElement()
+		r.ReadEndElement()
+	}
+	r.ReadEndElement()
+
+	r.ReadStartElement() // storage
+	switch weighted {
+	case true:
+		_ = r.ReadTypeDescr(weightStorageType)
+		r.ReadStartElement() // impl
+		_ = r.ReadTypeDescr(weightStorageImplType)
+		r.ReadStartElement() // vector
+		_ = r.ReadTypeDescr(weightedSumsType)
+		n := r.readLen()
+		r.readItemVersion(weightedSumType)
+		for i := 0; i < n && r.err == nil; i++ {
+			r.ReadStartElement()
+			_ = r.ReadTypeDescr(weightedSumType)
+			h.Weights = append(h.Weights, boostio.WeightedSum{
+				SumOfWeights:        r.ReadF64(),
+				SumOfWeightsSquared: r.ReadF64(),
+			})
+			r.ReadEndElement()
+		}
+	default:
+		_ = r.ReadTypeDescr(intStorageType)
+		r.ReadStartElement() // impl
+		_ = r.ReadTypeDescr(intStorageImplType)
+		r.ReadStartElement() // vector
+		n := r.readLen()
+		r.readItemVersion(reflect.TypeOf(int32(0)))
+		for i := 0; i < n && r.err == nil; i++ {
+			h.Counts = append(h.Counts, r.ReadI32())
+		}
+	}
+	r.ReadEndElement()
+	r.ReadEndElement()
+	r.ReadEndElement()
+	r.ReadEndElement()
+
+	if r.err != nil {
+		return boostio.Histogram{Variant: variant, Weighted: weighted}
+	}
+	return h
+}
+
+func (r *RBuffer) readAxis(kind boostio.AxisKind) boostio.Axis {
+	r.ReadStartElement()
+	defer r.ReadEndElement()
+
+	switch kind {
+	case boostio.RegularAxisKind:
+		_ = r.ReadTypeDescr(regularAxisType)
+		r.ReadStartElement() // transform
+		_ = r.ReadTypeDescr(transformIDType)
+		r.ReadEndElement()
+		var axis boostio.RegularAxis
+		axis.Size = r.ReadI32()
+		axis.Meta = r.ReadString()
+		axis.Min = r.ReadF64()
+		axis.Delta = r.ReadF64()
+		return axis
+	case boostio.VariableAxisKind:
+		_ = r.ReadTypeDescr(variableAxisType)
+		var axis boostio.VariableAxis
+		r.ReadStartElement() // seq
+		n := r.readLen()
+		r.readItemVersion(reflect.TypeOf(float64(0)))
+		for i := 0; i < n && r.err == nil; i++ {
+			axis.Edges = append(axis.Edges, r.ReadF64())
+		}
+		r.ReadEndElement()
+		axis.Meta = r.ReadString()
+		return axis
+	case boostio.IntegerAxisKind:
+		_ = r.ReadTypeDescr(integerAxisType)
+		var axis boostio.IntegerAxis
+		axis.Size = r.ReadI32()
+		axis.Meta = r.ReadString()
+		axis.Min = r.ReadI32()
+		return axis
+	case boostio.CategoryAxisKind:
+		_ = r.ReadTypeDescr(categoryAxisType)
+		var axis boostio.CategoryAxis
+		r.ReadStartElement() // seq
+		n := r.readLen()
+		r.readItemVersion(reflect.TypeOf(int32(0)))
+		for i := 0; i < n && r.err == nil; i++ {
+			axis.Values = append(axis.Values, r.ReadI32())
+		}
+		r.ReadEndElement()
+		axis.Meta = r.ReadString()
+		return axis
+	case boostio.StrCategoryAxisKind:
+		_ = r.ReadTypeDescr(strCategoryType)
+		var axis boostio.StrCategoryAxis
+		r.ReadStartElement() // seq
+		_ = r.ReadTypeDescr(stringsType)
+		n := r.readLen()
+		r.readItemVersion(stringsType.Elem())
+		for i := 0; i < n && r.err == nil; i++ {
+			axis.Values = append(axis.Values, r.ReadString())
+		}
+		r.ReadEndElement()
+		axis.Meta = r.ReadString()
+		return axis
+	}
+	r.err = ErrInvalidHistogram
+	return nil
+}
+
+// ReadPTree reads a boost::property_tree::ptree.
+func (r *RBuffer) ReadPTree() boostio.PTree {
+	r.enter()
+	defer r.leave()
+
+	var t boostio.PTree
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(ptreeType)
+	n := r.readLen()
+	if n > 0 {
+		r.readItemVersion(ptreeItemType)
+	}
+	for i := 0; i < n && r.err == nil; i++ {
+		r.ReadStartElement()
+		_ = r.ReadTypeDescr(ptreeItemType)
+		key := r.ReadString()
+		t.Add(key, r.ReadPTree())
+		r.ReadEndElement()
+	}
+	t.Data = r.ReadString()
+	r.ReadEndElement()
+	if r.err != nil {
+		return boostio.PTree{}
+	}
+	return t
+}
+
+// ReadDate reads a boost::gregorian::date.
+func (r *RBuffer) ReadDate() boostio.Date {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(dateType)
+	str := r.ReadString()
+	r.ReadEndElement()
+	if r.err != nil {
+		return boostio.Date{}
+	}
+	v, err := boostio.ParseDate(strings.TrimSpace(str))
+	if err != nil {
+		r.err = ErrInvalidDateTime
+		return boostio.Date{}
+	}
+	return v
+}
+
+// ReadPTime reads a boost::posix_time::ptime.
+func (r *RBuffer) ReadPTime() boostio.PTime {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(ptimeType)
+	d := r.ReadDate()
+	var td boostio.TimeDuration
+	if d.Special == boostio.NotSpecial {
+		td = r.ReadTimeDuration()
+	}
+	r.ReadEndElement()
+	switch {
+	case r.err != nil:
+		return boostio.PTime{}
+	case d.Special != boostio.NotSpecial:
+		return boostio.PTime{Special: d.Special}
+	case td.Special != boostio.NotSpecial:
+		return boostio.PTime{Special: td.Special}
+	}
+	return boostio.PTime{Time: d.Time.Add(td.Duration)}
+}
+
+// ReadTimeDuration reads a boost::posix_time::time_duration.
+func (r *RBuffer) ReadTimeDuration() boostio.TimeDuration {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(timeDurationType)
+	var v boostio.TimeDuration
+	switch r.ReadBool() {
+	case true:
+		v.Special = boostio.ParseSpecial(strings.TrimSpace(r.ReadString()))
+		if r.err == nil && v.Special == boostio.NotSpecial {
+			r.err = ErrInvalidDateTime
+		}
+	default:
+		h := r.ReadI64()
+		m := r.ReadI64()
+		s := r.ReadI64()
+		us := r.ReadI64()
+		v.Duration = makeDuration(h, m, s, us)
+	}
+	r.ReadEndElement()
+	if r.err != nil {
+		return boostio.TimeDuration{}
+	}
+	return v
+}
+
+// readNodePosition reads the position of a node of a multi_index_container,
+// and returns its object ID if nodes are tracked.
+func (r *RBuffer) readNodePosition(node reflect.Type, tracked bool) uint32 {
+	r.ReadStartElement()
+	_ = r.ReadTypeDescr(node)
+	var oid uint32
+	if tracked {
+		oid = r.readObjectID("object_id")
+	}
+	r.ReadEndElement()
+	return oid
+}
+
+// readPointer reads a pointer to a tracked object, and returns the ID of
+// that object. ok is false for a null pointer.
+func (r *RBuffer) readPointer() (oid uint32, ok bool) {
+	r.ReadStartElement()
+	null := false
+	for _, attr := range r.start.Attr {
+		if attr.Name.Local == "class_id" && attr.Value == "-1" {
+			null = true
+		}
+	}
+	if !null {
+		oid = r.readObjectID("object_id_reference")
+	}
+	r.ReadEndElement()
+	return oid, !null && r.err == nil
+}
+
+// readObjectID reads the object ID (e.g. "_3") held by the attribute name of
+// the last opened element.
+func (r *RBuffer) readObjectID(name string) uint32 {
+	if r.err != nil {
+		return 0
+	}
+	for _, attr := range r.start.Attr {
+		if attr.Name.Local != name {
+			continue
+		}
+		v, err := strconv.ParseUint(strings.TrimPrefix(attr.Value, "_"), 10, 32)
+		if err != nil {
+			r.err = ErrInvalidElement
+		}
+		return uint32(v)
+	}
+	r.err = ErrInvalidElement
+	return 0
+}
+
+// decodeElement decodes the content of the already opened start element
+// into v.
+func (r *RBuffer) decodeElement(v interface{}, start *xml.StartElement) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.dec.DecodeElement(v, start)
+}
+
 func (r *RBuffer) ReadBool() bool {
 	if r.err != nil {
 		return false
@@ -128,6 +128,7 @@ int main()
   auto v21 = animal("pet", 4, 1);
   auto v22 = std::vector<std::string>({"s1", "s2", "s3"});
   auto v23 = std::vector<animal>({animal("tiger",4,1), animal("monkey",4,1)});
+  auto v24 = std::vector<bool>({true, false, true});
 
   oa
 	<< BOOST_SERIALIZATION_NVP(v1)
@@ -153,6 +154,7 @@ int main()
 	<< BOOST_SERIALIZATION_NVP(v21)
 	<< BOOST_SERIALIZATION_NVP(v22)
 	<< BOOST_SERIALIZATION_NVP(v23)
+	<< BOOST_SERIALIZATION_NVP(v24)
 	;
 }
 `
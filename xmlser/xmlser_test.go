@@ -4,6 +4,12 @@
 
 package xmlser_test
 
+import (
+	"reflect"
+
+	"github.com/go-boostio/boostio/xmlser"
+)
+
 var typeTestCases = []struct {
 	name string
 	want interface{}
@@ -31,6 +37,7 @@ var typeTestCases = []struct {
 	{"struct-marshal", manimal{"pet", 4, 1}},
 	{"[]string", []string{"s1", "s2", "s3"}},
 	{"[]animal", []manimal{{"tiger", 4, 1}, {"monkey", 4, 1}}},
+	{"[]bool", []bool{true, false, true}},
 }
 
 type animal struct {
@@ -39,12 +46,68 @@ type animal struct {
 	Tails int8
 }
 
+type book struct {
+	IDs []int32 `boost:",multi_index=ordered_unique|hashed_unique"`
+}
+
+type order struct {
+	ID int32
+}
+
+type queue struct {
+	Orders []order `boost:",multi_index=sequenced|ordered_non_unique"`
+}
+
+type grid struct {
+	Cells  [2][3]int16 `boost:",carray"`
+	Orders [2]order    `boost:",carray"`
+	Dims   [2]int32    `boost:",stdarray"`
+}
+
+type blob struct {
+	Count  int32
+	Values []float32 `boost:",array,len=Count"`
+	Size   uint8
+	Data   []byte  `boost:",binary,len=Size"`
+	Magic  [4]byte `boost:",binary"`
+	Orders []order `boost:",array,len=2"`
+}
+
+type color uint8
+
+const (
+	red color = iota
+	green
+	blue
+)
+
+func (color) EnumValues() []int64 { return []int64{int64(red), int64(green), int64(blue)} }
+
+type pixel struct {
+	Color color
+	Alpha int8
+}
+
 type manimal struct {
 	name  string
 	legs  int16
 	tails int8
 }
 
-// var (
-// 	animalType = reflect.TypeOf((*animal)(nil)).Elem()
-// )
+var (
+	animalType = reflect.TypeOf((*animal)(nil)).Elem()
+)
+
+func (a *manimal) UnmarshalBoostXML(r *xmlser.RBuffer) error {
+	r.ReadStartElement()
+	r.ReadTypeDescr(animalType) // use same type as animal.
+	a.name = r.ReadString()
+	a.legs = r.ReadI16()
+	a.tails = r.ReadI8()
+	r.ReadEndElement()
+	return r.Err()
+}
+
+var (
+	_ xmlser.Unmarshaler = (*manimal)(nil)
+)